@@ -0,0 +1,82 @@
+package common
+
+import "math"
+
+// HSV is a color in the hue, saturation and value color space. H is the hue in degrees, in [0, 360); S and V are in
+// [0, 1].
+type HSV struct {
+	H, S, V float64
+}
+
+// HSV converts the color to HSV. The hue of gray colors is 0.
+func (c Color) HSV() HSV {
+	r := float64((c>>16)&0xFF) / 0xFF
+	g := float64((c>>8)&0xFF) / 0xFF
+	b := float64(c&0xFF) / 0xFF
+
+	cmax := math.Max(r, math.Max(g, b))
+	cmin := math.Min(r, math.Min(g, b))
+	delta := cmax - cmin
+
+	hsv := HSV{V: cmax}
+	if cmax == 0 || delta == 0 {
+		return hsv
+	}
+	hsv.S = delta / cmax
+
+	switch cmax {
+	case r:
+		hsv.H = 60 * math.Mod((g-b)/delta, 6)
+	case g:
+		hsv.H = 60 * ((b-r)/delta + 2)
+	default:
+		hsv.H = 60 * ((r-g)/delta + 4)
+	}
+
+	if hsv.H < 0 {
+		hsv.H += 360
+	}
+
+	return hsv
+}
+
+// Color converts the HSV color to Color. The hue is wrapped to [0, 360), and the saturation and the value are clamped
+// to [0, 1].
+func (h HSV) Color() Color {
+	hue := math.Mod(h.H, 360)
+	if hue < 0 {
+		hue += 360
+	}
+	s := clamp(h.S)
+	v := clamp(h.V)
+
+	chroma := v * s
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := v - chroma
+
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = chroma, x, 0
+	case hue < 120:
+		r, g, b = x, chroma, 0
+	case hue < 180:
+		r, g, b = 0, chroma, x
+	case hue < 240:
+		r, g, b = 0, x, chroma
+	case hue < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+
+	return Color(toByte(r+m))<<16 | Color(toByte(g+m))<<8 | Color(toByte(b+m))
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func toByte(v float64) uint8 {
+	return uint8(math.Round(clamp(v) * 0xFF))
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the HSV conversion", func() {
+	DescribeTable("should convert the color to HSV", func(c Color, expected HSV) {
+		hsv := c.HSV()
+		Expect(hsv.H).Should(BeNumerically("~", expected.H, 0.5))
+		Expect(hsv.S).Should(BeNumerically("~", expected.S, 0.005))
+		Expect(hsv.V).Should(BeNumerically("~", expected.V, 0.005))
+	},
+		Entry("black", Color(0), HSV{}),
+		Entry("white", Color(0xFFFFFF), HSV{S: 0, V: 1}),
+		Entry("gray", Color(0x808080), HSV{S: 0, V: 0.502}),
+		Entry("red", Color(0xFF0000), HSV{H: 0, S: 1, V: 1}),
+		Entry("yellow", Color(0xFFFF00), HSV{H: 60, S: 1, V: 1}),
+		Entry("green", Color(0x00FF00), HSV{H: 120, S: 1, V: 1}),
+		Entry("cyan", Color(0x00FFFF), HSV{H: 180, S: 1, V: 1}),
+		Entry("blue", Color(0x0000FF), HSV{H: 240, S: 1, V: 1}),
+		Entry("magenta", Color(0xFF00FF), HSV{H: 300, S: 1, V: 1}),
+		Entry("rose", Color(0xFF0080), HSV{H: 329.9, S: 1, V: 1}),
+		Entry("dark orange", Color(0x804000), HSV{H: 30, S: 1, V: 0.502}),
+		Entry("pale blue", Color(0x8080FF), HSV{H: 240, S: 0.498, V: 1}),
+	)
+
+	DescribeTable("should convert HSV to color", func(hsv HSV, expected Color) {
+		Expect(hsv.Color()).Should(Equal(expected))
+	},
+		Entry("black", HSV{}, Color(0)),
+		Entry("white", HSV{V: 1}, Color(0xFFFFFF)),
+		Entry("red", HSV{H: 0, S: 1, V: 1}, Color(0xFF0000)),
+		Entry("orange", HSV{H: 30, S: 1, V: 1}, Color(0xFF8000)),
+		Entry("green", HSV{H: 120, S: 1, V: 1}, Color(0x00FF00)),
+		Entry("blue", HSV{H: 240, S: 1, V: 1}, Color(0x0000FF)),
+		Entry("half value", HSV{H: 0, S: 1, V: 0.5}, Color(0x800000)),
+		Entry("half saturation", HSV{H: 240, S: 0.5, V: 1}, Color(0x8080FF)),
+		Entry("wrap the hue", HSV{H: 480, S: 1, V: 1}, Color(0x00FF00)),
+		Entry("negative hue", HSV{H: -120, S: 1, V: 1}, Color(0x0000FF)),
+		Entry("clamp the saturation and the value", HSV{H: 0, S: 2, V: -1}, Color(0)),
+	)
+
+	It("should convert back and forth", func() {
+		for _, c := range []Color{0x123456, 0xABCDEF, 0xFF8000, 0x00FF80, 0x7F7F7F, 0x010203} {
+			Expect(c.HSV().Color()).Should(Equal(c), "%06x", uint32(c))
+		}
+	})
+})
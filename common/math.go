@@ -0,0 +1,22 @@
+package common
+
+// MinInt returns the smaller of a and b
+func MinInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// MaxInt returns the larger of a and b
+func MaxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ClampInt limits v to the range [low, high]
+func ClampInt(v, low, high int) int {
+	return MaxInt(low, MinInt(v, high))
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the int helpers", func() {
+	It("should return the smaller and the larger value", func() {
+		Expect(MinInt(3, -2)).Should(Equal(-2))
+		Expect(MinInt(-2, 3)).Should(Equal(-2))
+		Expect(MaxInt(3, -2)).Should(Equal(3))
+		Expect(MaxInt(-2, 3)).Should(Equal(3))
+	})
+
+	DescribeTable("should clamp the value", func(v, expected int) {
+		Expect(ClampInt(v, 0, 7)).Should(Equal(expected))
+	},
+		Entry("below the range", -1, 0),
+		Entry("in the range", 4, 4),
+		Entry("above the range", 8, 7),
+	)
+})
@@ -5,7 +5,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/nunnatsa/piHatDraw/common"
 	"github.com/nunnatsa/piHatDraw/hat"
@@ -14,29 +16,124 @@ import (
 	"github.com/nunnatsa/piHatDraw/webapp"
 )
 
+const (
+	// textColor is the color of the texts the controller scrolls on the display
+	textColor      common.Color = 0xFFFFFF
+	errorTextColor common.Color = 0xFF0000
+	// savePixelSize is the size of each canvas pixel in the saved image
+	savePixelSize = 10
+)
+
 type Controller struct {
 	hat            hat.Interface
 	joystickEvents chan hat.Event
 	screenEvents   chan hat.DisplayMessage
+	hatScreen      chan hat.DisplayMessage
+	texts          chan hat.TextMessage
+	display        *hat.Fanout
 	done           chan struct{}
 	state          *state.State
 	notifier       *notifier.Notifier
 	clientEvents   <-chan webapp.ClientEvent
+	keymap         Keymap
+	mode           inputMode
+	menu           *menu
+	picker         *picker
+	idle           *idleManager
+	saveDir        string
+	plot           *plot
+	openSensor     func(name string) (sensor, error)
+	// openColorSensor opens the color sensor of the eyedropper
+	openColorSensor func() (hat.ColorSensor, error)
+	whiteBalance    hat.RGBC
+	// deviceEvents are the events of the additional input devices; the HAT joystick is device 0
+	deviceEvents chan hat.DeviceEvent
+	inputs       []*hat.InputReader
+}
+
+// Options is the optional configuration of the controller
+type Options struct {
+	// HatName is the HAT backend; see hat.New
+	HatName string
+	// Hat is the configuration of the HAT backend
+	Hat hat.Options
+	// ExtraInputs are the additional input devices, each with its own cursor
+	ExtraInputs []hat.InputSelector
+	// Keymap overrides the default gesture bindings
+	Keymap Keymap
+	// Displays are the specs of the additional displays; see hat.NewDisplaySink
+	Displays []string
+	// SaveDir is the directory of the images that are saved from the HAT menu
+	SaveDir string
+	// Idle is when to dim and blank the display
+	Idle IdleSettings
+	// History is the limit of the undo history
+	History state.HistorySettings
 }
 
-func NewController(notifier *notifier.Notifier, clientEvents <-chan webapp.ClientEvent, canvasWidth uint8, canvasHeight uint8) *Controller {
+// NewController creates the controller. The display messages are sent to the HAT backend, and to the additional
+// displays; see hat.NewDisplaySink. Identical messages are skipped, and bursts of messages are coalesced; see
+// hat.FrameLimiter. Each of the additional input devices has its own cursor, tool and color; see state.SelectDevice.
+func NewController(notifier *notifier.Notifier, clientEvents <-chan webapp.ClientEvent, canvasWidth uint8, canvasHeight uint8, opts Options) (*Controller, error) {
 	je := make(chan hat.Event, 1)
 	se := make(chan hat.DisplayMessage, 1)
+	ls := make(chan hat.DisplayMessage, 1)
+	ds := make(chan hat.DisplayMessage, 1)
+	hs := make(chan hat.DisplayMessage)
+	texts := make(chan hat.TextMessage, 1)
+
+	h, err := hat.New(opts.HatName, je, hs, opts.Hat)
+	if err != nil {
+		return nil, err
+	}
+
+	hat.NewScroller(se, texts, ls).Start()
+	hat.NewFrameLimiter(ls, ds, hat.DefaultFrameInterval).Start()
+
+	display := hat.NewFanout(ds)
+	display.AddSink(hat.HatSinkName, hat.NewChannelSink(hs, h))
+	for _, spec := range opts.Displays {
+		sink, err := hat.NewDisplaySink(spec)
+		if err != nil {
+			return nil, err
+		}
+		display.AddSink(spec, sink)
+	}
+	display.Start()
+
+	de := make(chan hat.DeviceEvent, 1)
+	inputs := make([]*hat.InputReader, len(opts.ExtraInputs))
+	for i, selector := range opts.ExtraInputs {
+		inputs[i] = hat.NewInputReader(i+1, selector, de)
+	}
 
 	return &Controller{
-		hat:            hat.NewHat(je, se),
+		hat:            h,
 		joystickEvents: je,
+		deviceEvents:   de,
+		inputs:         inputs,
 		screenEvents:   se,
+		hatScreen:      hs,
+		texts:          texts,
+		display:        display,
 		done:           make(chan struct{}),
-		state:          state.NewState(canvasWidth, canvasHeight),
+		state:          state.NewStateWithHistory(canvasWidth, canvasHeight, opts.History),
 		notifier:       notifier,
 		clientEvents:   clientEvents,
-	}
+		keymap:         opts.Keymap,
+		menu:           newMenu(state.Palette()),
+		picker:         newPicker(),
+		idle:           newIdleManager(opts.Idle, time.Now),
+		saveDir:        opts.SaveDir,
+		openSensor:     openEnvironmentSensor,
+		openColorSensor: func() (hat.ColorSensor, error) {
+			s, err := hat.OpenColorSensor()
+			if err != nil {
+				return nil, err
+			}
+			return s, nil
+		},
+	}, nil
 }
 
 func (c Controller) Start() <-chan struct{} {
@@ -51,11 +148,29 @@ func (c *Controller) do() {
 
 	defer c.stop(signals)
 
-	c.hat.Start()
+	if err := c.hat.Start(); err != nil {
+		log.Printf("Can't start the HAT; running without a HAT; %v", err)
+		c.hat = hat.NewHeadlessFallback(c.joystickEvents, c.hatScreen, err)
+		_ = c.hat.Start()
+		c.display.ReplaceSink(hat.HatSinkName, hat.NewChannelSink(c.hatScreen, c.hat))
+	}
+
+	for _, input := range c.inputs {
+		if err := input.Start(); err != nil {
+			log.Printf("Can't start the input device; %v", err)
+		}
+	}
 
 	msg := c.state.CreateDisplayMessage()
 	c.screenEvents <- msg
 
+	var idleTick <-chan time.Time
+	if c.idle.enabled() {
+		ticker := time.NewTicker(idleTickInterval)
+		defer ticker.Stop()
+		idleTick = ticker.C
+	}
+
 	for {
 		var change *state.Change
 
@@ -64,10 +179,31 @@ func (c *Controller) do() {
 			return
 
 		case je := <-c.joystickEvents:
-			change = c.handleJoystickEvent(je)
+			if c.wake() {
+				// the event only wakes the display up
+				continue
+			}
+			change = c.handleDeviceEvent(hat.DeviceEvent{Event: je})
+
+		case de := <-c.deviceEvents:
+			if c.wake() {
+				continue
+			}
+			change = c.handleDeviceEvent(de)
 
 		case e := <-c.clientEvents:
+			if isUserActivity(e) {
+				c.wake()
+			}
 			change = c.handleWebClientEvent(e)
+
+		case <-idleTick:
+			if c.idle.tick(c.state.GetCanvasSize()) {
+				c.redraw()
+			}
+
+		case <-c.plotTick():
+			change = c.samplePlot()
 		}
 
 		if change != nil {
@@ -84,12 +220,13 @@ func (c *Controller) handleWebClientEvent(e webapp.ClientEvent) *state.Change {
 
 	case webapp.ClientEventReset:
 		if data {
+			c.showText("reset", textColor)
 			return c.state.Reset()
 		}
 
 	case webapp.ClientEventSetColor:
 		color := common.Color(data)
-		return c.state.SetColor(color)
+		return c.announce(c.state.SetColor(color))
 
 	case webapp.ClientEventSetTool:
 		var err error
@@ -98,19 +235,119 @@ func (c *Controller) handleWebClientEvent(e webapp.ClientEvent) *state.Change {
 			log.Println(err.Error())
 			return nil
 		}
-		return change
+		return c.announce(change)
 
 	case webapp.ClientEventDownload:
 		data <- c.state.GetCanvasClone()
+		c.showText("saved", textColor)
 
 	case webapp.ClientEventUndo:
 		return c.state.Undo()
+
+	case webapp.ClientEventRedo:
+		return c.state.Redo()
+
+	case webapp.ClientEventSetDisplaySettings:
+		change, err := c.setDisplaySettings(data)
+		if data.Result != nil {
+			data.Result <- err
+		} else if err != nil {
+			log.Println(err.Error())
+		}
+		return change
+
+	case webapp.ClientEventDisplayHealth:
+		data <- c.display.Health()
+
+	case webapp.ClientEventShowText:
+		c.showText(data.Text, data.Color)
+
+	case webapp.ClientEventJoystick:
+		return c.handleJoystickEvent(hat.Event(data))
+
+	case webapp.ClientEventPlot:
+		change, err := c.setPlot(data)
+		data.Result <- err
+		return change
+
+	case webapp.ClientEventPan:
+		if data.Absolute {
+			return c.state.PanTo(data.X, data.Y)
+		}
+		return c.state.Pan(data.DX, data.DY)
+
+	case webapp.ClientEventSnapToCursor:
+		return c.state.SnapToCursor()
+
+	case webapp.ClientEventDrawLine:
+		color := c.state.GetColor()
+		if data.Color != nil {
+			color = *data.Color
+		}
+		change, err := c.state.DrawLine(data.X0, data.Y0, data.X1, data.Y1, color)
+		data.Result <- err
+		return change
+
+	case webapp.ClientEventSetZoom:
+		change, err := c.state.SetZoom(uint8(data))
+		if err != nil {
+			log.Println(err.Error())
+			return nil
+		}
+		return change
 	}
 
 	return nil
 }
 
+func (c *Controller) setDisplaySettings(data webapp.ClientEventSetDisplaySettings) (*state.Change, error) {
+	settings := c.state.GetDisplaySettings()
+	if data.Gamma != nil {
+		settings.Gamma = *data.Gamma
+	}
+	if data.Brightness != nil {
+		settings.Brightness = *data.Brightness
+	}
+	if data.LowLight != nil {
+		settings.LowLight = *data.LowLight
+	}
+	if data.Dithering != nil {
+		settings.Dithering = *data.Dithering
+	}
+	if data.Downsample != nil {
+		settings.Downsample = *data.Downsample
+	}
+	if data.Cursor != nil {
+		settings.Cursor = *data.Cursor
+	}
+
+	return c.state.SetDisplaySettings(settings)
+}
+
+// handleDeviceEvent makes the device of the event the active device, so the display shows its window, and handles the
+// event
+func (c *Controller) handleDeviceEvent(de hat.DeviceEvent) *state.Change {
+	if change := c.state.SelectDevice(de.Device); change != nil {
+		c.Update(change)
+	}
+
+	return c.handleJoystickEvent(de.Event)
+}
+
 func (c *Controller) handleJoystickEvent(je hat.Event) *state.Change {
+	switch c.mode {
+	case modeMenu:
+		return c.handleMenuEvent(je)
+	case modePicker:
+		return c.handlePickerEvent(je)
+	case modePan:
+		return c.handlePanEvent(je)
+	}
+
+	if c.state.InOverview() {
+		return c.handleOverviewEvent(je)
+	}
+
 	switch je {
 	case hat.MoveUp:
 		return c.state.GoUp()
@@ -127,19 +364,304 @@ func (c *Controller) handleJoystickEvent(je hat.Event) *state.Change {
 	case hat.Pressed:
 		return c.state.Paint()
 	}
+
+	if action, ok := c.keymap[je]; ok {
+		return c.doAction(action)
+	}
+	return nil
+}
+
+// handleOverviewEvent moves the overview selection, and jumps to it when the joystick is pressed. The overview action
+// leaves the overview mode without moving the window.
+func (c *Controller) handleOverviewEvent(je hat.Event) *state.Change {
+	switch je {
+	case hat.MoveUp:
+		return c.state.MoveOverviewSelection(0, -1)
+
+	case hat.MoveLeft:
+		return c.state.MoveOverviewSelection(-1, 0)
+
+	case hat.MoveDown:
+		return c.state.MoveOverviewSelection(0, 1)
+
+	case hat.MoveRight:
+		return c.state.MoveOverviewSelection(1, 0)
+
+	case hat.Pressed:
+		return c.state.SelectOverview()
+	}
+
+	if c.keymap[je] == ActionOverview {
+		return c.state.ToggleOverview()
+	}
+	return nil
+}
+
+func (c *Controller) doAction(action Action) *state.Change {
+	switch action {
+	case ActionUndo:
+		return c.state.Undo()
+
+	case ActionRedo:
+		return c.state.Redo()
+
+	case ActionCycleTool:
+		return c.announce(c.state.CycleTool())
+
+	case ActionCycleColor:
+		return c.announce(c.state.CycleColor())
+
+	case ActionPageUp:
+		return c.state.PageUp()
+
+	case ActionPageDown:
+		return c.state.PageDown()
+
+	case ActionPageLeft:
+		return c.state.PageLeft()
+
+	case ActionPageRight:
+		return c.state.PageRight()
+
+	case ActionOverview:
+		return c.state.ToggleOverview()
+
+	case ActionZoomIn:
+		return c.state.ZoomIn()
+
+	case ActionZoomOut:
+		return c.state.ZoomOut()
+
+	case ActionMenu:
+		c.mode = modeMenu
+		c.menu.open()
+		c.redraw()
+
+	case ActionPicker:
+		c.openPicker()
+
+	case ActionEyedropper:
+		return c.eyedropper()
+
+	case ActionWhiteBalance:
+		c.calibrateWhiteBalance()
+
+	case ActionPan:
+		c.mode = modePan
+		c.showText("pan", textColor)
+
+	case ActionSnap:
+		return c.state.SnapToCursor()
+	}
 	return nil
 }
 
+// handlePanEvent moves the window with the joystick directions, without moving the cursor. Pressing the joystick, or
+// the pan action, leaves the pan mode, and keeps the window where it is; the snap action leaves the pan mode, and moves
+// the window back to the cursor.
+func (c *Controller) handlePanEvent(je hat.Event) *state.Change {
+	switch je {
+	case hat.MoveUp:
+		return c.state.Pan(0, -1)
+
+	case hat.MoveLeft:
+		return c.state.Pan(-1, 0)
+
+	case hat.MoveDown:
+		return c.state.Pan(0, 1)
+
+	case hat.MoveRight:
+		return c.state.Pan(1, 0)
+
+	case hat.Pressed:
+		c.mode = modeDraw
+		return nil
+	}
+
+	switch c.keymap[je] {
+	case ActionPan:
+		c.mode = modeDraw
+
+	case ActionSnap:
+		c.mode = modeDraw
+		return c.state.SnapToCursor()
+	}
+	return nil
+}
+
+func (c *Controller) openPicker() {
+	c.mode = modePicker
+	c.picker.open(c.state.GetColor())
+	c.redraw()
+}
+
+// handlePickerEvent passes the joystick event to the color picker, and sets the color when it's selected. The menu and
+// the picker actions close the picker without changing the color.
+func (c *Controller) handlePickerEvent(je hat.Event) *state.Change {
+	if action := c.keymap[je]; action == ActionMenu || action == ActionPicker {
+		c.mode = modeDraw
+		c.redraw()
+		return nil
+	}
+
+	if !c.picker.handle(je) {
+		c.redraw()
+		return nil
+	}
+
+	c.mode = modeDraw
+	change := c.announce(c.state.SetColor(c.picker.color()))
+	if change == nil {
+		c.redraw()
+	}
+	return change
+}
+
+// handleMenuEvent passes the joystick event to the menu, and does what the menu selected. The menu action closes the
+// menu without doing anything.
+func (c *Controller) handleMenuEvent(je hat.Event) *state.Change {
+	if c.keymap[je] == ActionMenu {
+		c.mode = modeDraw
+		c.redraw()
+		return nil
+	}
+
+	var change *state.Change
+	switch c.menu.handle(je) {
+	case menuNone:
+		c.redraw()
+		return nil
+
+	case menuSetTool:
+		change, _ = c.state.SetTool(c.menu.toolName())
+		change = c.announce(change)
+
+	case menuSetColor:
+		change = c.announce(c.state.SetColor(c.menu.selectedColor()))
+
+	case menuColorPicker:
+		c.openPicker()
+		return nil
+
+	case menuEyedropper:
+		change = c.eyedropper()
+
+	case menuUndo:
+		change = c.state.Undo()
+
+	case menuReset:
+		c.showText("reset", textColor)
+		change = c.state.Reset()
+
+	case menuSave:
+		c.save()
+	}
+
+	c.mode = modeDraw
+	if change == nil {
+		c.redraw()
+	}
+	return change
+}
+
+// readColorSensor reads the color sensor. On error, the error text is displayed.
+func (c *Controller) readColorSensor() (hat.RGBC, bool) {
+	sensor, err := c.openColorSensor()
+	if err == nil {
+		var reading hat.RGBC
+		if reading, err = sensor.ReadRGBC(); err == nil {
+			return reading, true
+		}
+	}
+
+	log.Println("Can't read the color sensor;", err)
+	c.showText("error", errorTextColor)
+	return hat.RGBC{}, false
+}
+
+// eyedropper sets the color to the color of the object in front of the color sensor
+func (c *Controller) eyedropper() *state.Change {
+	reading, ok := c.readColorSensor()
+	if !ok {
+		return nil
+	}
+
+	return c.announce(c.state.SetColor(reading.Color(c.whiteBalance)))
+}
+
+// calibrateWhiteBalance uses the color of the object in front of the color sensor as white
+func (c *Controller) calibrateWhiteBalance() {
+	reading, ok := c.readColorSensor()
+	if !ok {
+		return
+	}
+
+	if reading.R == 0 || reading.G == 0 || reading.B == 0 || reading.C == 0 {
+		log.Printf("Can't use %+v as the white balance; too dark", reading)
+		c.showText("dark", errorTextColor)
+		return
+	}
+
+	c.whiteBalance = reading
+	c.showText("white", textColor)
+}
+
+// save writes the canvas to a new PNG file in the save directory
+func (c *Controller) save() {
+	fileName := filepath.Join(c.saveDir, time.Now().Format("piHatDraw-20060102-150405.png"))
+	f, err := os.Create(fileName)
+	if err != nil {
+		log.Println("can't save the canvas;", err)
+		c.showText("error", errorTextColor)
+		return
+	}
+
+	err = webapp.WriteImage(f, c.state.GetCanvasClone(), savePixelSize)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Println("can't save the canvas;", err)
+		c.showText("error", errorTextColor)
+		return
+	}
+
+	log.Println("saved the canvas to", fileName)
+	c.showText("saved", textColor)
+}
+
 func (c *Controller) stop(signals chan os.Signal) {
+	if c.plot != nil {
+		c.plot.stop()
+	}
+	for _, input := range c.inputs {
+		input.Stop()
+	}
+	stopDisplay(c.screenEvents, c.display, c.joystickEvents)
 	c.hat.Stop()
 	<-c.joystickEvents // wait for the hat graceful shutdown
 	signal.Stop(signals)
 	close(c.done)
 }
 
+// stopDisplay closes the display pipeline, and waits for the display sinks to show the last message and to close. The
+// joystick events are dropped meanwhile, so the HAT is not blocked on them while the sinks are waiting for it.
+func stopDisplay(screenEvents chan<- hat.DisplayMessage, display *hat.Fanout, joystickEvents <-chan hat.Event) {
+	close(screenEvents)
+	for {
+		select {
+		case <-display.Done():
+			return
+		case _, ok := <-joystickEvents:
+			if !ok {
+				joystickEvents = nil
+			}
+		}
+	}
+}
+
 func (c *Controller) Update(change *state.Change) {
-	msg := c.state.CreateDisplayMessage()
-	c.screenEvents <- msg
+	c.redraw()
 
 	js, err := json.Marshal(change)
 	if err != nil {
@@ -149,6 +671,169 @@ func (c *Controller) Update(change *state.Change) {
 	}
 }
 
+// setPlot starts the sensor plot, or stops it if no sensor is selected. A running plot is replaced.
+func (c *Controller) setPlot(data webapp.ClientEventPlot) (*state.Change, error) {
+	if data.Sensor == "" {
+		if c.plot != nil {
+			c.plot.stop()
+			c.plot = nil
+		}
+		return nil, nil
+	}
+
+	settings := PlotSettings{
+		Sensor:   data.Sensor,
+		X:        data.X,
+		Y:        data.Y,
+		Width:    data.Width,
+		Height:   data.Height,
+		Style:    data.Style,
+		Min:      data.Min,
+		Max:      data.Max,
+		Colors:   data.Colors,
+		Interval: data.Interval,
+	}.withDefaults()
+
+	if err := settings.validate(c.state.GetCanvasSize()); err != nil {
+		return nil, err
+	}
+
+	s, err := c.openSensor(settings.Sensor)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.plot != nil {
+		c.plot.stop()
+	}
+	c.plot = newPlot(settings, s)
+
+	return c.samplePlot(), nil
+}
+
+// plotTick returns the sampling channel of the sensor plot, or nil if there is no plot
+func (c *Controller) plotTick() <-chan time.Time {
+	if c.plot == nil {
+		return nil
+	}
+	return c.plot.ticker.C
+}
+
+// samplePlot reads the sensor, and draws the chart on the canvas. The plot is stopped if the sensor can't be read.
+func (c *Controller) samplePlot() *state.Change {
+	if err := c.plot.sample(); err != nil {
+		log.Printf("Can't read the %s sensor; stopping the plot; %v", c.plot.settings.Sensor, err)
+		c.plot.stop()
+		c.plot = nil
+		c.showText("error", errorTextColor)
+		return nil
+	}
+
+	settings := c.plot.settings
+	return c.state.PaintRegion(settings.X, settings.Y, c.plot.render())
+}
+
+// isUserActivity returns false for the client events that don't come from a user; e.g. a health monitor
+func isUserActivity(e webapp.ClientEvent) bool {
+	_, isHealth := e.(webapp.ClientEventDisplayHealth)
+	return !isHealth
+}
+
+// wake records the user activity, and redraws the display if it was idle. It returns true if the display was
+// sleeping.
+func (c *Controller) wake() bool {
+	prev := c.idle.activity()
+	if prev == idleActive {
+		return false
+	}
+
+	c.redraw()
+	return prev == idleSleeping
+}
+
+// redraw sends the display message of the current input mode and idle state
+func (c *Controller) redraw() {
+	var msg hat.DisplayMessage
+	switch c.idle.state {
+	case idleSleeping:
+		msg = c.sleepingMessage()
+	default:
+		msg = c.displayMessage()
+	}
+
+	if c.idle.state != idleActive {
+		settings := c.idle.dim(*msg.Settings)
+		msg.Settings = &settings
+	}
+
+	c.screenEvents <- msg
+}
+
+// displayMessage returns the display message of the current input mode. The color picker uses the cursor as its
+// selector.
+func (c *Controller) displayMessage() hat.DisplayMessage {
+	var msg hat.DisplayMessage
+	switch c.mode {
+	case modeMenu:
+		msg = hat.NewDisplayMessage(c.menu.screen(), 0, 0)
+		msg.NoCursor = true
+
+	case modePicker:
+		x, y := c.picker.cursor()
+		msg = hat.NewDisplayMessage(c.picker.screen(), x, y)
+
+	default:
+		return c.state.CreateDisplayMessage()
+	}
+
+	settings := c.state.GetDisplaySettings()
+	msg.Settings = &settings
+	return msg
+}
+
+// sleepingMessage returns the screensaver window, or a blank display
+func (c *Controller) sleepingMessage() hat.DisplayMessage {
+	if c.idle.settings.Screensaver {
+		return c.state.CreateWindowMessage(c.idle.screensaverWindow())
+	}
+
+	screen := make([][]common.Color, common.WindowSize)
+	for y := range screen {
+		screen[y] = make([]common.Color, common.WindowSize)
+	}
+
+	msg := hat.NewDisplayMessage(screen, 0, 0)
+	msg.NoCursor = true
+	settings := c.state.GetDisplaySettings()
+	msg.Settings = &settings
+	return msg
+}
+
+// announce scrolls the new tool or color of the change on the display, so the joystick user knows about them. The
+// color is written in the new color.
+func (c *Controller) announce(change *state.Change) *state.Change {
+	if change == nil {
+		return nil
+	}
+
+	if change.ToolName != "" {
+		c.showText(change.ToolName, textColor)
+	}
+	if change.Color != nil {
+		c.showText("color", *change.Color)
+	}
+
+	return change
+}
+
+// showText scrolls the text on the display. If the display is busy with too many texts, the text is dropped.
+func (c *Controller) showText(text string, color common.Color) {
+	select {
+	case c.texts <- hat.TextMessage{Text: text, Color: color}:
+	default:
+	}
+}
+
 func (c *Controller) registered(id uint64) {
 	change := c.state.GetFullChange()
 	js, err := json.Marshal(change)
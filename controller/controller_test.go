@@ -2,7 +2,11 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -52,6 +56,12 @@ var _ = Describe("Controller Test", func() {
 	done := make(chan struct{})
 	defer close(done)
 
+	keymap, err := ParseKeymap("DoublePressed=overview,LongPressed=cycleTool,Shaken=menu")
+	Expect(err).ToNot(HaveOccurred())
+
+	saveDir, err := os.MkdirTemp("", "piHatDraw")
+	Expect(err).ToNot(HaveOccurred())
+
 	c := &Controller{
 		hat:            hatMock,
 		joystickEvents: je,
@@ -60,6 +70,18 @@ var _ = Describe("Controller Test", func() {
 		state:          s,
 		notifier:       n,
 		clientEvents:   ce,
+		keymap:         keymap,
+		texts:          make(chan hat.TextMessage, 10),
+		menu:           newMenu(state.Palette()),
+		picker:         newPicker(),
+		saveDir:        saveDir,
+		idle:           newIdleManager(IdleSettings{}, time.Now),
+		openSensor: func(name string) (sensor, error) {
+			return &fakeSensor{values: []float64{10}}, nil
+		},
+		openColorSensor: func() (hat.ColorSensor, error) {
+			return fakeColorSensor{reading: hat.RGBC{R: 100, G: 50, B: 0, C: 150}}, nil
+		},
 	}
 
 	c.Start()
@@ -350,6 +372,36 @@ var _ = Describe("Controller Test", func() {
 		Consistently(reg2).ShouldNot(Receive())
 	})
 
+	It("should set the display settings", func() {
+		brightness := uint8(40)
+		lowLight := true
+		ce <- webapp.ClientEventSetDisplaySettings{Brightness: &brightness, LowLight: &lowLight}
+
+		Eventually(func() bool {
+			msg := <-c.screenEvents
+			Expect(msg.Settings).ShouldNot(BeNil())
+			Expect(msg.Settings.Brightness).Should(Equal(brightness))
+			Expect(msg.Settings.LowLight).Should(BeTrue())
+			Expect(msg.Settings.Gamma).Should(Equal(hat.DefaultGammaTable))
+			return true
+		}).Should(BeTrue())
+
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webMsg.DisplaySettings).ShouldNot(BeNil())
+			Expect(webMsg.DisplaySettings.Brightness).Should(Equal(brightness))
+			Expect(webMsg.DisplaySettings.LowLight).Should(BeTrue())
+		}
+
+		By("should ignore wrong settings")
+		brightness = 120
+		ce <- webapp.ClientEventSetDisplaySettings{Brightness: &brightness}
+		Consistently(c.screenEvents).ShouldNot(Receive())
+		Consistently(reg1).ShouldNot(Receive())
+		Consistently(reg2).ShouldNot(Receive())
+	})
+
 	It("should use bucket", func() {
 		clr := common.Color(0x00112233)
 		change, err := c.state.SetTool(bucketToolName)
@@ -416,8 +468,483 @@ var _ = Describe("Controller Test", func() {
 		}).Should(BeTrue())
 
 	})
+
+	It("should page right with a gesture", func() {
+		hatMock.Send(hat.DoubleMoveRight)
+
+		Eventually(func() bool {
+			msg := <-c.screenEvents
+			Expect(msg.CursorX).Should(BeEquivalentTo(4))
+			Expect(msg.CursorY).Should(BeEquivalentTo(4))
+			Expect(msg.WindowX).Should(BeEquivalentTo(x + 4))
+			return true
+		}).Should(BeTrue())
+
+		Eventually(func() bool {
+			return checkMoveNotifications(<-reg1, x+8, y)
+		}).Should(BeTrue())
+
+		Eventually(func() bool {
+			return checkMoveNotifications(<-reg2, x+8, y)
+		}).Should(BeTrue())
+
+		By("should cycle the tool with a long press")
+		hatMock.Send(hat.LongPressed)
+
+		Eventually(c.screenEvents).Should(Receive())
+
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webMsg.ToolName).To(Equal(eraserToolName))
+		}
+	})
+
+	It("should jump to the region selected in the overview", func() {
+		hatMock.Send(hat.DoublePressed)
+
+		Eventually(func() bool {
+			msg := <-c.screenEvents
+			Expect(msg.CursorX).Should(BeEquivalentTo(5))
+			Expect(msg.CursorY).Should(BeEquivalentTo(4))
+			return true
+		}).Should(BeTrue())
+
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webMsg.Overview).ShouldNot(BeNil())
+			Expect(*webMsg.Overview).Should(BeTrue())
+		}
+
+		By("move the selection")
+		hatMock.MoveRight()
+		Eventually(func() bool {
+			msg := <-c.screenEvents
+			Expect(msg.CursorX).Should(BeEquivalentTo(6))
+			Expect(msg.CursorY).Should(BeEquivalentTo(4))
+			return true
+		}).Should(BeTrue())
+		Eventually(reg1).Should(Receive())
+		Eventually(reg2).Should(Receive())
+
+		By("jump to the selection")
+		hatMock.Press()
+		Eventually(func() bool {
+			msg := <-c.screenEvents
+			Expect(msg.WindowX).Should(BeEquivalentTo(28))
+			Expect(msg.WindowY).Should(BeEquivalentTo(9))
+			Expect(msg.CursorX).Should(BeEquivalentTo(4))
+			Expect(msg.CursorY).Should(BeEquivalentTo(4))
+			return true
+		}).Should(BeTrue())
+
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webMsg.Cursor.X).Should(BeEquivalentTo(32))
+			Expect(webMsg.Cursor.Y).Should(BeEquivalentTo(13))
+			Expect(*webMsg.Overview).Should(BeFalse())
+		}
+	})
+
+	It("should zoom", func() {
+		ce <- webapp.ClientEventSetZoom(2)
+
+		Eventually(func() bool {
+			msg := <-c.screenEvents
+			Expect(msg.Zoom).Should(BeEquivalentTo(2))
+			Expect(msg.WindowX).Should(BeEquivalentTo(30))
+			Expect(msg.WindowY).Should(BeEquivalentTo(11))
+			Expect(msg.CursorX).Should(BeEquivalentTo(4))
+			Expect(msg.CursorY).Should(BeEquivalentTo(4))
+			return true
+		}).Should(BeTrue())
+
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webMsg.Zoom).Should(BeEquivalentTo(2))
+			Expect(webMsg.Window.X).Should(BeEquivalentTo(30))
+		}
+
+		By("should ignore wrong zoom")
+		ce <- webapp.ClientEventSetZoom(3)
+		Consistently(c.screenEvents).ShouldNot(Receive())
+		Consistently(reg1).ShouldNot(Receive())
+		Consistently(reg2).ShouldNot(Receive())
+	})
+	It("should set the cursor style", func() {
+		cursor := hat.CursorSettings{Style: hat.CursorCrosshair, Color: 0x00FF00, Blink: true}
+		ce <- webapp.ClientEventSetDisplaySettings{Cursor: &cursor}
+
+		Eventually(func() bool {
+			msg := <-c.screenEvents
+			Expect(msg.Settings.Cursor).Should(Equal(cursor))
+			return true
+		}).Should(BeTrue())
+
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webMsg.DisplaySettings.Cursor).Should(Equal(cursor))
+		}
+
+		By("should ignore unknown style")
+		ce <- webapp.ClientEventSetDisplaySettings{Cursor: &hat.CursorSettings{Style: "underline"}}
+		Consistently(c.screenEvents).ShouldNot(Receive())
+		Consistently(reg1).ShouldNot(Receive())
+		Consistently(reg2).ShouldNot(Receive())
+
+		By("should return the error of the unknown style")
+		result := make(chan error, 1)
+		ce <- webapp.ClientEventSetDisplaySettings{Cursor: &hat.CursorSettings{Style: "underline"}, Result: result}
+		Eventually(result).Should(Receive(HaveOccurred()))
+		Consistently(reg1).ShouldNot(Receive())
+	})
+
+	It("should scroll the new color, and the user texts", func() {
+		for len(c.texts) > 0 {
+			<-c.texts
+		}
+
+		ce <- webapp.ClientEventSetColor(0x00FFFF)
+		Eventually(c.texts).Should(Receive(Equal(hat.TextMessage{Text: "color", Color: 0x00FFFF})))
+		<-c.screenEvents
+		<-reg1
+		<-reg2
+
+		ce <- webapp.ClientEventShowText{Text: "hello", Color: 0xFF0000}
+		Eventually(c.texts).Should(Receive(Equal(hat.TextMessage{Text: "hello", Color: 0xFF0000})))
+		Consistently(c.screenEvents).ShouldNot(Receive())
+	})
+
+	It("should select the tool from the menu", func() {
+		hatMock.Send(hat.Shaken)
+		msg := <-c.screenEvents
+		Expect(msg.NoCursor).Should(BeTrue())
+		Expect(msg.Screen[menuIndicatorLine][0]).Should(Equal(menuMarkColor))
+
+		hatMock.Send(hat.MoveRight)
+		<-c.screenEvents
+		hatMock.Send(hat.MoveRight)
+		msg = <-c.screenEvents
+		Expect(msg.Screen[menuIndicatorLine][2]).Should(Equal(menuMarkColor))
+		Consistently(reg1).ShouldNot(Receive(), "the menu does not move the cursor")
+
+		hatMock.Send(hat.Pressed)
+		msg = <-c.screenEvents
+		Expect(msg.NoCursor).Should(BeFalse())
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(webMsg.ToolName).To(Equal(bucketToolName))
+		}
+
+		By("leaving the menu with the menu gesture")
+		hatMock.Send(hat.Shaken)
+		Expect((<-c.screenEvents).NoCursor).Should(BeTrue())
+		hatMock.Send(hat.Shaken)
+		Expect((<-c.screenEvents).NoCursor).Should(BeFalse())
+		Consistently(reg1).ShouldNot(Receive())
+	})
+
+	It("should pick a color from the color picker", func() {
+		hatMock.Send(hat.Shaken)
+		<-c.screenEvents
+		for menuItems[c.menu.item].name != "picker" {
+			hatMock.Send(hat.MoveRight)
+			<-c.screenEvents
+		}
+
+		hatMock.Send(hat.Pressed)
+		msg := <-c.screenEvents
+		Expect(msg.NoCursor).Should(BeFalse(), "the cursor is the selector")
+		Expect(msg.Screen[0][0]).Should(Equal(common.Color(0xFF0000)))
+		Expect(msg.CursorX).Should(BeEquivalentTo(4), "the current color is cyan")
+		Expect(msg.CursorY).Should(BeEquivalentTo(0))
+
+		hatMock.Send(hat.MoveRight)
+		<-c.screenEvents
+		hatMock.Send(hat.Pressed)
+		<-c.screenEvents
+		hatMock.Send(hat.MoveDown)
+		<-c.screenEvents
+		hatMock.Send(hat.Pressed)
+
+		expected := common.HSV{H: 225, S: 1, V: 1 - 1.0/7}.Color()
+		msg = <-c.screenEvents
+		Expect(msg.NoCursor).Should(BeFalse())
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*webMsg.Color).To(Equal(expected))
+		}
+	})
+
+	It("should save the canvas from the menu", func() {
+		defer os.RemoveAll(saveDir)
+
+		hatMock.Send(hat.Shaken)
+		<-c.screenEvents
+		hatMock.Send(hat.MoveLeft)
+		<-c.screenEvents
+		hatMock.Send(hat.Pressed)
+		<-c.screenEvents
+
+		files, err := filepath.Glob(filepath.Join(saveDir, "piHatDraw-*.png"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).Should(HaveLen(1))
+	})
+
+	It("should handle the joystick events from a follower", func() {
+		ce <- webapp.ClientEventJoystick(hat.MoveRight)
+		<-c.screenEvents
+		right, err := getChangeFromMsg(<-reg1)
+		Expect(err).ToNot(HaveOccurred())
+		<-reg2
+
+		ce <- webapp.ClientEventJoystick(hat.MoveLeft)
+		<-c.screenEvents
+		left, err := getChangeFromMsg(<-reg1)
+		Expect(err).ToNot(HaveOccurred())
+		<-reg2
+
+		Expect(left.Cursor.X).Should(Equal(right.Cursor.X - 1))
+	})
+
+	It("should plot the sensor", func() {
+		plotColor := common.Color(0x123456)
+		plotEvent := webapp.ClientEventPlot{
+			Sensor: hat.SensorTemperature,
+			Width:  2,
+			Height: 2,
+			Max:    10,
+			Colors: []common.Color{plotColor},
+			Result: make(chan error, 1),
+		}
+
+		ce <- plotEvent
+		Expect(<-plotEvent.Result).ShouldNot(HaveOccurred())
+		<-c.screenEvents
+		Expect(checkPaintNotifications(<-reg1, state.Pixel{X: 1, Y: 0, Color: plotColor}, state.Pixel{X: 1, Y: 1, Color: plotColor})).Should(BeTrue())
+		<-reg2
+
+		By("stopping the plot")
+		stopEvent := webapp.ClientEventPlot{Result: make(chan error, 1)}
+		ce <- stopEvent
+		Expect(<-stopEvent.Result).ShouldNot(HaveOccurred())
+
+		By("rejecting a region outside the canvas")
+		plotEvent.X = canvasWidth - 1
+		ce <- plotEvent
+		Expect(<-plotEvent.Result).Should(HaveOccurred())
+	})
+
+	It("should set the color from the eyedropper in the menu", func() {
+		hatMock.Send(hat.Shaken)
+		<-c.screenEvents
+		for menuItems[c.menu.item].name != "eyedropper" {
+			hatMock.MoveRight()
+			<-c.screenEvents
+		}
+		hatMock.Press()
+		<-c.screenEvents
+
+		expected := common.Color(0xFF8000)
+		for _, reg := range []chan []byte{reg1, reg2} {
+			webMsg, err := getChangeFromMsg(<-reg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*webMsg.Color).To(Equal(expected))
+		}
+	})
+})
+
+var _ = Describe("test the eyedropper", func() {
+	var (
+		c      *Controller
+		sensor *fakeColorSensor
+	)
+
+	BeforeEach(func() {
+		sensor = &fakeColorSensor{}
+		c = &Controller{
+			state: state.NewState(canvasWidth, canvasHeight),
+			texts: make(chan hat.TextMessage, 10),
+			openColorSensor: func() (hat.ColorSensor, error) {
+				return sensor, nil
+			},
+		}
+	})
+
+	It("should use the white balance", func() {
+		sensor.reading = hat.RGBC{R: 200, G: 100, B: 50, C: 300}
+		c.calibrateWhiteBalance()
+		Expect(<-c.texts).Should(Equal(hat.TextMessage{Text: "white", Color: textColor}))
+
+		sensor.reading = hat.RGBC{R: 100, G: 100, B: 50, C: 150}
+		change := c.eyedropper()
+		Expect(change).ShouldNot(BeNil())
+		Expect(*change.Color).Should(Equal(common.Color(0x408080)))
+		Expect(c.state.GetColor()).Should(Equal(common.Color(0x408080)))
+	})
+
+	It("should not use a dark white balance", func() {
+		sensor.reading = hat.RGBC{R: 200, G: 0, B: 50, C: 300}
+		c.calibrateWhiteBalance()
+		Expect(c.whiteBalance).Should(Equal(hat.RGBC{}))
+		Expect((<-c.texts).Color).Should(Equal(errorTextColor))
+	})
+
+	It("should show an error if there is no sensor", func() {
+		c.openColorSensor = func() (hat.ColorSensor, error) {
+			return nil, errors.New("no sensor")
+		}
+
+		Expect(c.eyedropper()).Should(BeNil())
+		Expect(<-c.texts).Should(Equal(hat.TextMessage{Text: "error", Color: errorTextColor}))
+	})
+})
+
+var _ = Describe("test several input devices", func() {
+	It("should show the window of the last active device", func() {
+		c := &Controller{
+			state:        state.NewState(canvasWidth, canvasHeight),
+			screenEvents: make(chan hat.DisplayMessage, 10),
+			notifier:     notifier.NewNotifier(),
+			idle:         newIdleManager(IdleSettings{}, time.Now),
+		}
+
+		Expect(c.handleDeviceEvent(hat.DeviceEvent{Event: hat.MoveLeft})).ShouldNot(BeNil())
+
+		By("moving the second device, from the middle of the canvas")
+		Expect(c.handleDeviceEvent(hat.DeviceEvent{Device: 1, Event: hat.MoveDown})).ShouldNot(BeNil())
+		msg := <-c.screenEvents
+		Expect(msg.CursorX).Should(BeEquivalentTo(4))
+		Expect(msg.CursorY).Should(BeEquivalentTo(4))
+
+		change := c.handleDeviceEvent(hat.DeviceEvent{Device: 1, Event: hat.MoveDown})
+		Expect(change.Cursors).Should(HaveLen(2))
+		Expect(change.Cursors[0]).Should(HaveField("X", x-1))
+		Expect(change.Cursors[1]).Should(HaveField("Y", y+2))
+
+		By("switching back to the first device")
+		change = c.handleDeviceEvent(hat.DeviceEvent{Event: hat.MoveUp})
+		Expect(change.Cursor.X).Should(Equal(x - 1))
+		Expect(change.Cursor.Y).Should(Equal(y - 1))
+	})
+})
+
+var _ = Describe("test the pan mode", func() {
+	var c *Controller
+
+	BeforeEach(func() {
+		keymap, err := ParseKeymap("DoublePressed=pan,LongPressed=snap")
+		Expect(err).ToNot(HaveOccurred())
+
+		c = &Controller{
+			state:        state.NewState(canvasWidth, canvasHeight),
+			screenEvents: make(chan hat.DisplayMessage, 10),
+			texts:        make(chan hat.TextMessage, 10),
+			notifier:     notifier.NewNotifier(),
+			idle:         newIdleManager(IdleSettings{}, time.Now),
+			keymap:       keymap,
+		}
+	})
+
+	It("should move the window without moving the cursor", func() {
+		Expect(c.handleJoystickEvent(hat.DoublePressed)).Should(BeNil())
+		Expect(c.mode).Should(Equal(modePan))
+		Expect(<-c.texts).Should(HaveField("Text", "pan"))
+
+		change := c.handleJoystickEvent(hat.MoveLeft)
+		Expect(change.Window.X).Should(Equal(x - 5))
+		Expect(change.Cursor.X).Should(Equal(x))
+
+		By("leaving the pan mode, and moving the cursor")
+		Expect(c.handleJoystickEvent(hat.Pressed)).Should(BeNil())
+		Expect(c.mode).Should(Equal(modeDraw))
+		change = c.handleJoystickEvent(hat.MoveDown)
+		Expect(change.Cursor.Y).Should(Equal(y + 1))
+	})
+
+	It("should snap back to the cursor", func() {
+		c.handleJoystickEvent(hat.DoublePressed)
+		c.handleJoystickEvent(hat.MoveUp)
+		c.handleJoystickEvent(hat.MoveUp)
+
+		change := c.handleJoystickEvent(hat.LongPressed)
+		Expect(change.Window.Y).Should(Equal(y - 4))
+		Expect(c.mode).Should(Equal(modeDraw))
+	})
+
+	It("should pan from the web client", func() {
+		change := c.handleWebClientEvent(webapp.ClientEventPan{X: 0, Y: 0, Absolute: true})
+		Expect(change.Window.X).Should(BeEquivalentTo(0))
+
+		change = c.handleWebClientEvent(webapp.ClientEventPan{DX: 2})
+		Expect(change.Window.X).Should(BeEquivalentTo(2))
+
+		change = c.handleWebClientEvent(webapp.ClientEventSnapToCursor(true))
+		Expect(change.Window.X).Should(Equal(x - 4))
+	})
 })
 
+var _ = Describe("test undo and redo", func() {
+	It("should redo the undone step", func() {
+		keymap, err := ParseKeymap("LongPressed=redo")
+		Expect(err).ToNot(HaveOccurred())
+
+		c := &Controller{
+			state:  state.NewState(canvasWidth, canvasHeight),
+			keymap: keymap,
+		}
+
+		Expect(*c.handleJoystickEvent(hat.Pressed).CanUndo).Should(BeTrue())
+
+		change := c.handleWebClientEvent(webapp.ClientEventUndo(true))
+		Expect(change.Pixels).Should(Equal([]state.Pixel{{X: x, Y: y, Color: 0}}))
+		Expect(*change.CanRedo).Should(BeTrue())
+
+		change = c.handleJoystickEvent(hat.LongPressed)
+		Expect(change.Pixels).Should(Equal([]state.Pixel{{X: x, Y: y, Color: 0xFFFFFF}}))
+		Expect(*change.CanRedo).Should(BeFalse())
+
+		c.handleWebClientEvent(webapp.ClientEventUndo(true))
+		Expect(c.handleWebClientEvent(webapp.ClientEventRedo(true))).ShouldNot(BeNil())
+	})
+})
+
+var _ = Describe("test the line from the web client", func() {
+	It("should draw the line in the current color, or in the requested color", func() {
+		c := &Controller{state: state.NewState(canvasWidth, canvasHeight)}
+
+		event := webapp.ClientEventDrawLine{X0: 0, Y0: 0, X1: 2, Y1: 0, Result: make(chan error, 1)}
+		change := c.handleWebClientEvent(event)
+		Expect(<-event.Result).ShouldNot(HaveOccurred())
+		Expect(change.Pixels).Should(HaveLen(3))
+		Expect(change.Pixels[2]).Should(Equal(state.Pixel{X: 2, Y: 0, Color: 0xFFFFFF}))
+
+		color := common.Color(0x00FF00)
+		event = webapp.ClientEventDrawLine{X0: 0, Y0: 0, X1: 0, Y1: 1, Color: &color, Result: make(chan error, 1)}
+		change = c.handleWebClientEvent(event)
+		Expect(<-event.Result).ShouldNot(HaveOccurred())
+		Expect(change.Pixels).Should(ConsistOf(state.Pixel{X: 0, Y: 0, Color: color}, state.Pixel{X: 0, Y: 1, Color: color}))
+
+		event = webapp.ClientEventDrawLine{X0: 0, Y0: 0, X1: canvasWidth, Y1: 1, Result: make(chan error, 1)}
+		Expect(c.handleWebClientEvent(event)).Should(BeNil())
+		Expect(<-event.Result).Should(HaveOccurred())
+	})
+})
+
+type fakeColorSensor struct {
+	reading hat.RGBC
+}
+
+func (s fakeColorSensor) ReadRGBC() (hat.RGBC, error) {
+	return s.reading, nil
+}
+
 func checkMoveNotifications(msg []byte, x uint8, y uint8) bool {
 	webMsg, err := getChangeFromMsg(msg)
 	ExpectWithOffset(1, err).ToNot(HaveOccurred())
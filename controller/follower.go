@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+	"github.com/nunnatsa/piHatDraw/state"
+	"github.com/nunnatsa/piHatDraw/webapp"
+)
+
+const (
+	// reconnectInterval is the time between two attempts to connect to the leader
+	reconnectInterval = 2 * time.Second
+	// forwardQueueSize is the number of joystick events that may wait to be forwarded to the leader; more events are
+	// dropped
+	forwardQueueSize = 8
+)
+
+// Follower is a piece of a video wall. It keeps a copy of the leader's canvas, and displays a fixed region of it on its
+// own HAT. The leader's cursor is displayed when it's inside the region. Optionally, the joystick events are forwarded
+// to the leader.
+type Follower struct {
+	hat             hat.Interface
+	joystickEvents  chan hat.Event
+	screenEvents    chan hat.DisplayMessage
+	hatScreen       chan hat.DisplayMessage
+	display         *hat.Fanout
+	done            chan struct{}
+	leader          *webapp.Client
+	regionX         uint8
+	regionY         uint8
+	forwardJoystick bool
+	// quit stops the follower, like the stop signals
+	quit chan struct{}
+
+	// the copy of the leader's state
+	canvas    state.Canvas
+	hasCursor bool
+	cursorX   uint8
+	cursorY   uint8
+	settings  *hat.DisplaySettings
+}
+
+// NewFollower creates a follower of the leader in leaderAddress, that displays the 8X8 region of the leader's canvas
+// that starts at regionX, regionY.
+func NewFollower(leaderAddress string, regionX, regionY uint8, forwardJoystick bool, hatName string, hatOptions hat.Options) (*Follower, error) {
+	leader, err := webapp.NewClient(leaderAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	je := make(chan hat.Event, 1)
+	hs := make(chan hat.DisplayMessage)
+
+	h, err := hat.New(hatName, je, hs, hatOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFollower(leader, h, je, hs, regionX, regionY, forwardJoystick), nil
+}
+
+// newFollower creates the follower with its HAT backend. As in the controller, the display messages are sent to the
+// HAT through a frame limiter and a fanout, so the follower never waits for the HAT.
+func newFollower(leader *webapp.Client, h hat.Interface, je chan hat.Event, hs chan hat.DisplayMessage, regionX, regionY uint8, forwardJoystick bool) *Follower {
+	se := make(chan hat.DisplayMessage, 1)
+	ds := make(chan hat.DisplayMessage, 1)
+
+	hat.NewFrameLimiter(se, ds, hat.DefaultFrameInterval).Start()
+
+	display := hat.NewFanout(ds)
+	display.AddSink(hat.HatSinkName, hat.NewChannelSink(hs, h))
+	display.Start()
+
+	return &Follower{
+		hat:             h,
+		joystickEvents:  je,
+		screenEvents:    se,
+		hatScreen:       hs,
+		display:         display,
+		done:            make(chan struct{}),
+		quit:            make(chan struct{}),
+		leader:          leader,
+		regionX:         regionX,
+		regionY:         regionY,
+		forwardJoystick: forwardJoystick,
+	}
+}
+
+func (f *Follower) Start() <-chan struct{} {
+	go f.do()
+	return f.done
+}
+
+func (f *Follower) do() {
+	// Set up a signals channel (stop the loop using Ctrl-C)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	defer f.stop(signals)
+
+	if err := f.hat.Start(); err != nil {
+		log.Printf("Can't start the HAT; running without a HAT; %v", err)
+		f.hat = hat.NewHeadlessFallback(f.joystickEvents, f.hatScreen, err)
+		_ = f.hat.Start()
+		f.display.ReplaceSink(hat.HatSinkName, hat.NewChannelSink(f.hatScreen, f.hat))
+	}
+
+	f.redraw()
+
+	changes := make(chan *state.Change)
+	stopFollowing := make(chan struct{})
+	defer close(stopFollowing)
+	go f.follow(changes, stopFollowing)
+
+	forward := make(chan hat.Event, forwardQueueSize)
+	go f.forward(forward, stopFollowing)
+
+	for {
+		select {
+		case <-signals:
+			return
+
+		case <-f.quit:
+			return
+
+		case je := <-f.joystickEvents:
+			if !f.forwardJoystick {
+				continue
+			}
+
+			select {
+			case forward <- je:
+			default:
+				log.Printf("The leader is busy; dropping the joystick event %v", je)
+			}
+
+		case change := <-changes:
+			f.apply(change)
+			f.redraw()
+		}
+	}
+}
+
+func (f *Follower) stop(signals chan os.Signal) {
+	stopDisplay(f.screenEvents, f.display, f.joystickEvents)
+	f.hat.Stop()
+	<-f.joystickEvents // wait for the hat graceful shutdown
+	signal.Stop(signals)
+	close(f.done)
+}
+
+// forward sends the joystick events to the leader. It's running in its own goroutine, so a slow leader does not delay
+// the display.
+func (f *Follower) forward(events <-chan hat.Event, stop <-chan struct{}) {
+	for {
+		select {
+		case je := <-events:
+			if err := f.leader.SendJoystickEvent(je); err != nil {
+				log.Println(err)
+			}
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// follow connects to the leader, and sends its changes to the changes channel. If the connection is lost, follow
+// reconnects; the leader sends its full state on each connection, so the copy is synchronized again.
+func (f *Follower) follow(changes chan<- *state.Change, stop <-chan struct{}) {
+	for {
+		conn, err := f.leader.Connect()
+		if err != nil {
+			log.Printf("Can't follow the leader; %v", err)
+		} else {
+			log.Println("Following the leader")
+			f.read(conn, changes, stop)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+func (f *Follower) read(conn *webapp.Connection, changes chan<- *state.Change, stop <-chan struct{}) {
+	closed := make(chan struct{})
+	defer close(closed)
+
+	// unblock conn.Next when stopping
+	go func() {
+		select {
+		case <-stop:
+		case <-closed:
+		}
+		_ = conn.Close()
+	}()
+
+	for {
+		change, err := conn.Next()
+		if err != nil {
+			select {
+			case <-stop:
+			default:
+				log.Printf("Lost the connection to the leader; %v", err)
+			}
+			return
+		}
+
+		select {
+		case changes <- change:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// apply updates the copy of the leader's state
+func (f *Follower) apply(change *state.Change) {
+	if change.Canvas != nil {
+		f.canvas = change.Canvas
+	}
+
+	for _, pixel := range change.Pixels {
+		if int(pixel.Y) < len(f.canvas) && int(pixel.X) < len(f.canvas[pixel.Y]) {
+			f.canvas[pixel.Y][pixel.X] = pixel.Color
+		}
+	}
+
+	if change.Cursor != nil {
+		f.hasCursor = true
+		f.cursorX = change.Cursor.X
+		f.cursorY = change.Cursor.Y
+	}
+
+	if change.DisplaySettings != nil {
+		settings := *change.DisplaySettings
+		f.settings = &settings
+	}
+}
+
+func (f *Follower) redraw() {
+	f.screenEvents <- f.displayMessage()
+}
+
+// displayMessage creates the display message of the region. The pixels outside the leader's canvas are off.
+func (f *Follower) displayMessage() hat.DisplayMessage {
+	screen := make([][]common.Color, common.WindowSize)
+	for y := range screen {
+		screen[y] = make([]common.Color, common.WindowSize)
+		canvasY := int(f.regionY) + y
+		if canvasY >= len(f.canvas) {
+			continue
+		}
+
+		for x := range screen[y] {
+			if canvasX := int(f.regionX) + x; canvasX < len(f.canvas[canvasY]) {
+				screen[y][x] = f.canvas[canvasY][canvasX]
+			}
+		}
+	}
+
+	msg := hat.NewDisplayMessage(screen, 0, 0)
+	msg.WindowX = f.regionX
+	msg.WindowY = f.regionY
+	msg.Settings = f.settings
+	msg.NoCursor = true
+
+	if f.hasCursor && f.inRegion(f.cursorX, f.cursorY) {
+		msg.CursorX = f.cursorX - f.regionX
+		msg.CursorY = f.cursorY - f.regionY
+		msg.NoCursor = false
+	}
+
+	return msg
+}
+
+func (f *Follower) inRegion(x, y uint8) bool {
+	return int(x) >= int(f.regionX) && int(x) < int(f.regionX)+common.WindowSize &&
+		int(y) >= int(f.regionY) && int(y) < int(f.regionY)+common.WindowSize
+}
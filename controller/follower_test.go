@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+	"github.com/nunnatsa/piHatDraw/notifier"
+	"github.com/nunnatsa/piHatDraw/state"
+	"github.com/nunnatsa/piHatDraw/webapp"
+)
+
+var _ = Describe("test the follower", func() {
+	Context("with a leader", func() {
+		var (
+			n       *notifier.Notifier
+			ce      chan webapp.ClientEvent
+			mux     *http.ServeMux
+			server  *httptest.Server
+			je      chan hat.Event
+			hs      chan hat.DisplayMessage
+			mock    *hatMock
+			f       *Follower
+			stopped <-chan struct{}
+		)
+
+		BeforeEach(func() {
+			n = notifier.NewNotifier()
+			ce = make(chan webapp.ClientEvent, 1)
+			mux = http.NewServeMux()
+			mux.Handle("/", webapp.NewWebApplication(n, ce).GetMux())
+			server = httptest.NewServer(mux)
+
+			leader, err := webapp.NewClient(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			je = make(chan hat.Event, 1)
+			hs = make(chan hat.DisplayMessage)
+			mock = &hatMock{je: je, se: hs}
+			f = newFollower(leader, mock, je, hs, 8, 8, true)
+		})
+
+		AfterEach(func() {
+			// the test is the HAT, so it reads the display messages until the follower is stopped
+			go func(hs <-chan hat.DisplayMessage, stopped <-chan struct{}) {
+				for {
+					select {
+					case <-hs:
+					case <-stopped:
+						return
+					}
+				}
+			}(hs, stopped)
+
+			close(f.quit)
+			Eventually(stopped).Should(BeClosed())
+			server.Close()
+			n.Close()
+		})
+
+		// connect starts the follower, and sends it the full state of the leader
+		connect := func() {
+			stopped = f.Start()
+
+			By("displaying nothing before the leader is connected")
+			msg := <-hs
+			Expect(msg.NoCursor).Should(BeTrue())
+			Expect(msg.Screen[0][0]).Should(Equal(common.Color(0)))
+
+			var id webapp.ClientEventRegistered
+			Eventually(ce).Should(Receive(&id))
+
+			By("receiving the full state of the leader")
+			leaderState := state.NewState(24, 16)
+			js, err := json.Marshal(leaderState.GetFullChange())
+			Expect(err).ToNot(HaveOccurred())
+			n.NotifyOne(uint64(id), js)
+
+			msg = <-hs
+			Expect(msg.WindowX).Should(BeEquivalentTo(8))
+			Expect(msg.WindowY).Should(BeEquivalentTo(8))
+			Expect(msg.NoCursor).Should(BeFalse())
+			Expect(msg.CursorX).Should(BeEquivalentTo(4), "the leader's cursor is at 12, 8")
+			Expect(msg.CursorY).Should(BeEquivalentTo(0))
+			Expect(msg.Settings).ShouldNot(BeNil())
+		}
+
+		It("should display the region of the leader's canvas", func() {
+			connect()
+
+			By("receiving a change")
+			n.NotifyAll([]byte(`{"cursor": {"x": 0, "y": 0}, "pixels": [{"x": 9, "y": 10, "color": "#ff0000"}]}`))
+
+			msg := <-hs
+			Expect(msg.NoCursor).Should(BeTrue(), "the cursor is outside the region")
+			Expect(msg.Screen[2][1]).Should(Equal(common.Color(0xFF0000)))
+
+			By("forwarding the joystick events")
+			mock.MoveUp()
+			Eventually(ce).Should(Receive(Equal(webapp.ClientEventJoystick(hat.MoveUp))))
+		})
+
+		It("should keep displaying the changes when the leader is slow", func() {
+			release := make(chan struct{})
+			defer close(release)
+			mux.HandleFunc("/api/joystick", func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			})
+
+			connect()
+
+			By("sending more joystick events than the forward queue can hold")
+			for i := 0; i < forwardQueueSize+4; i++ {
+				mock.MoveUp()
+			}
+
+			By("receiving a change while the leader is still busy with the first event")
+			n.NotifyAll([]byte(`{"pixels": [{"x": 9, "y": 10, "color": "#00ff00"}]}`))
+
+			var msg hat.DisplayMessage
+			Eventually(hs).Should(Receive(&msg))
+			Expect(msg.Screen[2][1]).Should(Equal(common.Color(0x00FF00)))
+		})
+	})
+
+	It("should display the region outside the canvas as off", func() {
+		leaderState := state.NewState(24, 16)
+		f := &Follower{regionX: 20, regionY: 12}
+		f.apply(leaderState.GetFullChange())
+		f.apply(&state.Change{Pixels: []state.Pixel{{X: 23, Y: 12, Color: 0x00FF00}}})
+
+		msg := f.displayMessage()
+		Expect(msg.Screen[0][3]).Should(Equal(common.Color(0x00FF00)))
+		Expect(msg.Screen[0][4]).Should(Equal(common.Color(0)))
+		Expect(msg.Screen[4][0]).Should(Equal(common.Color(0)))
+		Expect(msg.NoCursor).Should(BeTrue())
+	})
+})
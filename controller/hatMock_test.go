@@ -9,8 +9,9 @@ type hatMock struct {
 	se chan hat.DisplayMessage
 }
 
-func (h *hatMock) Start() {
+func (h *hatMock) Start() error {
 	/* Implement hat.Interface */
+	return nil
 }
 
 func (h *hatMock) Stop() {
@@ -36,3 +37,7 @@ func (h *hatMock) MoveLeft() {
 func (h *hatMock) Press() {
 	h.je <- hat.Pressed
 }
+
+func (h *hatMock) Send(e hat.Event) {
+	h.je <- e
+}
@@ -0,0 +1,138 @@
+package controller
+
+import "github.com/nunnatsa/piHatDraw/common"
+
+// icon is a menu picture, drawn above the menu indicator line. Each character is a pixel, in the color of
+// iconColors; any other character is off.
+type icon [menuIndicatorLine]string
+
+var iconColors = map[byte]common.Color{
+	'W': 0xFFFFFF,
+	'R': 0xFF0000,
+	'O': 0xFF8000,
+	'Y': 0xFFFF00,
+	'G': 0x00FF00,
+	'C': 0x00FFFF,
+	'B': 0x0000FF,
+	'P': 0xFF6080,
+	'M': 0xFF00FF,
+}
+
+func (i icon) draw(screen [][]common.Color) {
+	for y, line := range i {
+		for x := 0; x < len(line) && x < common.WindowSize; x++ {
+			screen[y][x] = iconColors[line[x]]
+		}
+	}
+}
+
+var penIcon = icon{
+	"......RR",
+	".....YRR",
+	"....YYY.",
+	"...YYY..",
+	"..YYY...",
+	".WYY....",
+	".WW.....",
+}
+
+var eraserIcon = icon{
+	"........",
+	"....PPP.",
+	"...PPPP.",
+	"..WWPP..",
+	".WWWW...",
+	".WWW....",
+	"........",
+}
+
+var bucketIcon = icon{
+	"..WWW...",
+	".W...W..",
+	"BBBBBBB.",
+	"BBBBBBBB",
+	".BBBBB.B",
+	".BBBBB.B",
+	"..BBB...",
+}
+
+var lineIcon = icon{
+	"......WW",
+	"......WW",
+	".....C..",
+	"....C...",
+	"...C....",
+	"WWC.....",
+	"WW......",
+}
+
+var paletteIcon = icon{
+	"RRR.GGG.",
+	"RRR.GGG.",
+	"RRR.GGG.",
+	"........",
+	"BBB.YYY.",
+	"BBB.YYY.",
+	"BBB.YYY.",
+}
+
+var pickerIcon = icon{
+	"ROYGCBM.",
+	"ROYGCBM.",
+	"ROYGCBM.",
+	"........",
+	"WWWWWWW.",
+	"........",
+	"........",
+}
+
+var eyedropperIcon = icon{
+	"......WW",
+	".....WWW",
+	"....WWW.",
+	"...CWW..",
+	"..C.C...",
+	".C.C....",
+	"CCC.....",
+}
+
+var undoIcon = icon{
+	"..W.....",
+	".WW.....",
+	"WWWWWW..",
+	".WW...W.",
+	"..W....W",
+	".......W",
+	".....WW.",
+}
+
+var resetIcon = icon{
+	"R.....R.",
+	".R...R..",
+	"..R.R...",
+	"...R....",
+	"..R.R...",
+	".R...R..",
+	"R.....R.",
+}
+
+var saveIcon = icon{
+	"...G....",
+	"...G....",
+	"...G....",
+	".GGGGG..",
+	"..GGG...",
+	"...G....",
+	"WWWWWWW.",
+}
+
+// confirmIcon is "no" on the left half, and "yes" on the right half
+var confirmIcon = icon{
+	"........",
+	"R..R....",
+	".RR....G",
+	".RR.G.G.",
+	"R..R.G..",
+	"........",
+	"........",
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+const (
+	idleTickInterval = 250 * time.Millisecond
+	// screensaverStep is the vertical step of the screensaver, between two horizontal passes
+	screensaverStep = common.WindowSize / 2
+)
+
+// IdleSettings configures what the display does when nobody uses the application
+type IdleSettings struct {
+	// DimAfter is the idle time before the display is dimmed. Zero disables the idle manager.
+	DimAfter time.Duration
+	// DimBrightness is the percentage of the brightness, while the display is dimmed
+	DimBrightness uint8
+	// SleepAfter is the idle time before the display is blanked, or the screensaver starts. Zero keeps the display
+	// dimmed.
+	SleepAfter time.Duration
+	// Screensaver pans the window across the canvas while sleeping, instead of blanking the display
+	Screensaver bool
+	// PanInterval is the time between two moves of the screensaver
+	PanInterval time.Duration
+}
+
+var DefaultIdleSettings = IdleSettings{
+	DimAfter:      2 * time.Minute,
+	DimBrightness: 30,
+	SleepAfter:    10 * time.Minute,
+	PanInterval:   500 * time.Millisecond,
+}
+
+type idleState int
+
+const (
+	idleActive idleState = iota
+	idleDimmed
+	idleSleeping
+)
+
+// idleManager tracks the user activity, and moves the display from active to dimmed, and then to sleeping. While
+// sleeping, the screensaver pans the window across the canvas, row after row, going back and forth.
+type idleManager struct {
+	settings     IdleSettings
+	now          hat.Clock
+	lastActivity time.Time
+	state        idleState
+
+	// the screensaver window
+	x, y    int
+	dx, dy  int
+	nextPan time.Time
+	maxX    int
+	maxY    int
+}
+
+func newIdleManager(settings IdleSettings, clock hat.Clock) *idleManager {
+	return &idleManager{
+		settings:     settings,
+		now:          clock,
+		lastActivity: clock(),
+	}
+}
+
+func (m *idleManager) enabled() bool {
+	return m.settings.DimAfter > 0
+}
+
+// activity records the user activity, and returns the state before it
+func (m *idleManager) activity() idleState {
+	prev := m.state
+	m.lastActivity = m.now()
+	m.state = idleActive
+
+	return prev
+}
+
+// tick moves to the next state when its time has come, and moves the screensaver. It returns true if the display
+// should be redrawn. The canvas size is used by the screensaver.
+func (m *idleManager) tick(canvasWidth, canvasHeight uint8) bool {
+	if !m.enabled() {
+		return false
+	}
+
+	now := m.now()
+	idle := now.Sub(m.lastActivity)
+
+	switch m.state {
+	case idleActive:
+		if idle >= m.settings.DimAfter {
+			m.state = idleDimmed
+			return true
+		}
+
+	case idleDimmed:
+		if m.settings.SleepAfter > 0 && idle >= m.settings.SleepAfter {
+			m.state = idleSleeping
+			m.startScreensaver(canvasWidth, canvasHeight, now)
+			return true
+		}
+
+	case idleSleeping:
+		if m.settings.Screensaver && !now.Before(m.nextPan) {
+			m.nextPan = now.Add(m.settings.PanInterval)
+			m.pan()
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *idleManager) startScreensaver(canvasWidth, canvasHeight uint8, now time.Time) {
+	m.x, m.y = 0, 0
+	m.dx, m.dy = 1, 1
+	m.maxX = int(canvasWidth) - common.WindowSize
+	m.maxY = int(canvasHeight) - common.WindowSize
+	m.nextPan = now.Add(m.settings.PanInterval)
+}
+
+// pan moves the screensaver window one pixel. At the end of the row, the window moves down (or up) to the next row, and
+// the horizontal direction is reversed. At the bottom (or the top), the vertical direction is reversed.
+func (m *idleManager) pan() {
+	if x := m.x + m.dx; x >= 0 && x <= m.maxX {
+		m.x = x
+		return
+	}
+
+	m.dx = -m.dx
+	if (m.dy > 0 && m.y == m.maxY) || (m.dy < 0 && m.y == 0) {
+		m.dy = -m.dy
+	}
+	m.y = common.ClampInt(m.y+m.dy*screensaverStep, 0, m.maxY)
+}
+
+// screensaverWindow returns the window the screensaver shows
+func (m *idleManager) screensaverWindow() (uint8, uint8) {
+	return uint8(m.x), uint8(m.y)
+}
+
+// dim lowers the brightness of the display settings
+func (m *idleManager) dim(settings hat.DisplaySettings) hat.DisplaySettings {
+	settings.Brightness = uint8(uint16(settings.Brightness) * uint16(m.settings.DimBrightness) / 100)
+	return settings
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+var _ = Describe("test the idle manager", func() {
+	var (
+		now time.Time
+		m   *idleManager
+	)
+
+	clock := func() time.Time {
+		return now
+	}
+
+	settings := IdleSettings{
+		DimAfter:      time.Minute,
+		DimBrightness: 50,
+		SleepAfter:    2 * time.Minute,
+		PanInterval:   time.Second,
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		m = newIdleManager(settings, clock)
+	})
+
+	It("should dim and then sleep", func() {
+		now = now.Add(59 * time.Second)
+		Expect(m.tick(16, 16)).Should(BeFalse())
+		Expect(m.state).Should(Equal(idleActive))
+
+		now = now.Add(time.Second)
+		Expect(m.tick(16, 16)).Should(BeTrue())
+		Expect(m.state).Should(Equal(idleDimmed))
+		Expect(m.tick(16, 16)).Should(BeFalse())
+
+		now = now.Add(time.Minute)
+		Expect(m.tick(16, 16)).Should(BeTrue())
+		Expect(m.state).Should(Equal(idleSleeping))
+
+		By("not moving without the screensaver")
+		now = now.Add(time.Minute)
+		Expect(m.tick(16, 16)).Should(BeFalse())
+
+		By("waking up on activity")
+		Expect(m.activity()).Should(Equal(idleSleeping))
+		Expect(m.state).Should(Equal(idleActive))
+		Expect(m.activity()).Should(Equal(idleActive))
+
+		now = now.Add(59 * time.Second)
+		Expect(m.tick(16, 16)).Should(BeFalse())
+	})
+
+	It("should stay dimmed if sleep is disabled", func() {
+		s := settings
+		s.SleepAfter = 0
+		m = newIdleManager(s, clock)
+
+		now = now.Add(time.Hour)
+		Expect(m.tick(16, 16)).Should(BeTrue())
+		Expect(m.tick(16, 16)).Should(BeFalse())
+		Expect(m.state).Should(Equal(idleDimmed))
+	})
+
+	It("should do nothing if disabled", func() {
+		m = newIdleManager(IdleSettings{}, clock)
+		Expect(m.enabled()).Should(BeFalse())
+
+		now = now.Add(time.Hour)
+		Expect(m.tick(16, 16)).Should(BeFalse())
+		Expect(m.state).Should(Equal(idleActive))
+	})
+
+	It("should dim the brightness", func() {
+		dimmed := m.dim(hat.DisplaySettings{Brightness: 200})
+		Expect(dimmed.Brightness).Should(BeEquivalentTo(100))
+	})
+
+	It("should pan the screensaver across the canvas", func() {
+		s := settings
+		s.Screensaver = true
+		m = newIdleManager(s, clock)
+
+		now = now.Add(time.Minute)
+		Expect(m.tick(16, 16)).Should(BeTrue())
+		now = now.Add(time.Minute)
+		Expect(m.tick(16, 16)).Should(BeTrue())
+		Expect(m.state).Should(Equal(idleSleeping))
+
+		x, y := m.screensaverWindow()
+		Expect(x).Should(BeEquivalentTo(0))
+		Expect(y).Should(BeEquivalentTo(0))
+
+		By("waiting for the pan interval")
+		now = now.Add(time.Second / 2)
+		Expect(m.tick(16, 16)).Should(BeFalse())
+
+		type window struct{ x, y uint8 }
+		next := func() window {
+			now = now.Add(time.Second)
+			Expect(m.tick(16, 16)).Should(BeTrue())
+			x, y := m.screensaverWindow()
+			return window{x: x, y: y}
+		}
+
+		for x := uint8(1); x <= 8; x++ {
+			Expect(next()).Should(Equal(window{x: x, y: 0}))
+		}
+
+		By("moving to the next row, and reversing the direction")
+		Expect(next()).Should(Equal(window{x: 8, y: 4}))
+		Expect(next()).Should(Equal(window{x: 7, y: 4}))
+		for i := 0; i < 7; i++ {
+			next()
+		}
+		Expect(next()).Should(Equal(window{x: 0, y: 8}))
+
+		By("going back up from the bottom")
+		for i := 0; i < 8; i++ {
+			next()
+		}
+		Expect(next()).Should(Equal(window{x: 8, y: 4}))
+	})
+})
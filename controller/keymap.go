@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+// Action is what the controller does for a joystick gesture
+type Action string
+
+const (
+	ActionNone       Action = "none"
+	ActionUndo       Action = "undo"
+	ActionRedo       Action = "redo"
+	ActionCycleTool  Action = "cycleTool"
+	ActionCycleColor Action = "cycleColor"
+	ActionPageUp     Action = "pageUp"
+	ActionPageDown   Action = "pageDown"
+	ActionPageLeft   Action = "pageLeft"
+	ActionPageRight  Action = "pageRight"
+	ActionOverview   Action = "overview"
+	ActionZoomIn     Action = "zoomIn"
+	ActionZoomOut    Action = "zoomOut"
+	ActionMenu       Action = "menu"
+	ActionPicker     Action = "colorPicker"
+	ActionEyedropper Action = "eyedropper"
+	// ActionWhiteBalance reads a white object, as the white balance of the eyedropper
+	ActionWhiteBalance Action = "whiteBalance"
+	// ActionPan enters or leaves the pan mode, where the joystick directions move the window without moving the cursor
+	ActionPan Action = "pan"
+	// ActionSnap moves the window back to the cursor
+	ActionSnap Action = "snap"
+)
+
+var actions = []Action{
+	ActionNone,
+	ActionUndo,
+	ActionRedo,
+	ActionCycleTool,
+	ActionCycleColor,
+	ActionPageUp,
+	ActionPageDown,
+	ActionPageLeft,
+	ActionPageRight,
+	ActionOverview,
+	ActionZoomIn,
+	ActionZoomOut,
+	ActionMenu,
+	ActionPicker,
+	ActionEyedropper,
+	ActionWhiteBalance,
+	ActionPan,
+	ActionSnap,
+}
+
+// Keymap binds the joystick gesture events to actions
+type Keymap map[hat.Event]Action
+
+var DefaultKeymap = Keymap{
+	hat.DoublePressed:   ActionUndo,
+	hat.LongPressed:     ActionMenu,
+	hat.DoubleMoveUp:    ActionPageUp,
+	hat.DoubleMoveDown:  ActionPageDown,
+	hat.DoubleMoveLeft:  ActionPageLeft,
+	hat.DoubleMoveRight: ActionPageRight,
+	hat.Shaken:          ActionUndo,
+}
+
+// ParseKeymap overrides the default keymap with a comma separated list of <event>=<action>; e.g.
+// "LongPressed=cycleColor,DoublePressed=none"
+func ParseKeymap(s string) (Keymap, error) {
+	keymap := make(Keymap, len(DefaultKeymap))
+	for event, action := range DefaultKeymap {
+		keymap[event] = action
+	}
+
+	if len(s) == 0 {
+		return keymap, nil
+	}
+
+	for _, binding := range strings.Split(s, ",") {
+		eventName, actionName, found := strings.Cut(strings.TrimSpace(binding), "=")
+		if !found {
+			return nil, fmt.Errorf(`wrong key binding "%s"; should be <event>=<action>`, binding)
+		}
+
+		event, err := hat.ParseEvent(eventName)
+		if err != nil {
+			return nil, err
+		}
+
+		if !event.IsGesture() {
+			return nil, fmt.Errorf(`can't bind "%s"; only the gestures can be bound`, event)
+		}
+
+		action, err := parseAction(actionName)
+		if err != nil {
+			return nil, err
+		}
+
+		keymap[event] = action
+	}
+
+	return keymap, nil
+}
+
+func parseAction(name string) (Action, error) {
+	for _, action := range actions {
+		if string(action) == name {
+			return action, nil
+		}
+	}
+
+	names := make([]string, 0, len(actions))
+	for _, action := range actions {
+		names = append(names, string(action))
+	}
+	return "", fmt.Errorf(`unknown action "%s"; should be one of %s`, name, strings.Join(names, ", "))
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+var _ = Describe("test ParseKeymap", func() {
+	It("should return the default keymap", func() {
+		keymap, err := ParseKeymap("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keymap).Should(Equal(DefaultKeymap))
+	})
+
+	It("should override the default bindings", func() {
+		keymap, err := ParseKeymap("LongPressed=cycleColor, DoublePressed=none")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keymap[hat.LongPressed]).Should(Equal(ActionCycleColor))
+		Expect(keymap[hat.DoublePressed]).Should(Equal(ActionNone))
+		Expect(keymap[hat.DoubleMoveUp]).Should(Equal(ActionPageUp))
+
+		By("should not change the default keymap")
+		Expect(DefaultKeymap[hat.LongPressed]).Should(Equal(ActionMenu))
+	})
+
+	DescribeTable("should reject wrong keymaps", func(s string) {
+		_, err := ParseKeymap(s)
+		Expect(err).To(HaveOccurred())
+	},
+		Entry("missing action", "LongPressed"),
+		Entry("unknown event", "TriplePressed=undo"),
+		Entry("unknown action", "LongPressed=fly"),
+		Entry("press", "Pressed=undo"),
+		Entry("move", "LongPressed=menu,MoveUp=pageUp"),
+	)
+})
@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+// inputMode is where the controller routes the joystick events to
+type inputMode int
+
+const (
+	// modeDraw routes the joystick events to the state
+	modeDraw inputMode = iota
+	// modeMenu routes the joystick events to the menu
+	modeMenu
+	// modePicker routes the joystick events to the color picker
+	modePicker
+	// modePan moves the window with the joystick directions, without moving the cursor
+	modePan
+)
+
+// menuCommand is what the menu asks the controller to do. Any command but menuNone closes the menu.
+type menuCommand int
+
+const (
+	menuNone menuCommand = iota
+	menuSetTool
+	menuSetColor
+	menuColorPicker
+	menuEyedropper
+	menuUndo
+	menuReset
+	menuSave
+)
+
+type menuPage int
+
+const (
+	pageItems menuPage = iota
+	pagePalette
+	pageConfirmReset
+)
+
+type menuItem struct {
+	name string
+	icon icon
+}
+
+var menuItems = []menuItem{
+	{name: "pen", icon: penIcon},
+	{name: "eraser", icon: eraserIcon},
+	{name: "bucket", icon: bucketIcon},
+	{name: "line", icon: lineIcon},
+	{name: "palette", icon: paletteIcon},
+	{name: "picker", icon: pickerIcon},
+	{name: "eyedropper", icon: eyedropperIcon},
+	{name: "undo", icon: undoIcon},
+	{name: "reset", icon: resetIcon},
+	{name: "save", icon: saveIcon},
+}
+
+const (
+	menuMarkColor     common.Color = 0xFFFFFF
+	menuDimMarkColor  common.Color = 0x303030
+	menuIndicatorLine              = common.WindowSize - 1
+)
+
+// menu is the joystick driven menu. The items are displayed one at a time; the joystick moves left and right between
+// them, and the press selects the item. The picker item opens the color picker, and the eyedropper item reads the color
+// sensor. The palette and the reset items open a second page; moving up goes back to the items.
+type menu struct {
+	page    menuPage
+	item    int
+	color   int
+	confirm bool
+	palette []common.Color
+}
+
+func newMenu(palette []common.Color) *menu {
+	return &menu{palette: palette}
+}
+
+// open shows the first item
+func (m *menu) open() {
+	m.page = pageItems
+	m.item = 0
+}
+
+// handle handles a joystick event, and returns the command for the controller
+func (m *menu) handle(e hat.Event) menuCommand {
+	switch m.page {
+	case pagePalette:
+		return m.handlePalette(e)
+	case pageConfirmReset:
+		return m.handleConfirmReset(e)
+	}
+
+	switch e {
+	case hat.MoveLeft:
+		m.item = (m.item + len(menuItems) - 1) % len(menuItems)
+
+	case hat.MoveRight:
+		m.item = (m.item + 1) % len(menuItems)
+
+	case hat.Pressed:
+		switch menuItems[m.item].name {
+		case "palette":
+			m.page = pagePalette
+		case "picker":
+			return menuColorPicker
+		case "eyedropper":
+			return menuEyedropper
+		case "undo":
+			return menuUndo
+		case "reset":
+			m.page = pageConfirmReset
+			m.confirm = false
+		case "save":
+			return menuSave
+		default:
+			return menuSetTool
+		}
+	}
+
+	return menuNone
+}
+
+func (m *menu) handlePalette(e hat.Event) menuCommand {
+	switch e {
+	case hat.MoveLeft:
+		m.color = (m.color + len(m.palette) - 1) % len(m.palette)
+
+	case hat.MoveRight:
+		m.color = (m.color + 1) % len(m.palette)
+
+	case hat.MoveUp:
+		m.page = pageItems
+
+	case hat.Pressed:
+		return menuSetColor
+	}
+
+	return menuNone
+}
+
+func (m *menu) handleConfirmReset(e hat.Event) menuCommand {
+	switch e {
+	case hat.MoveLeft:
+		m.confirm = false
+
+	case hat.MoveRight:
+		m.confirm = true
+
+	case hat.MoveUp:
+		m.page = pageItems
+
+	case hat.Pressed:
+		if m.confirm {
+			return menuReset
+		}
+		m.page = pageItems
+	}
+
+	return menuNone
+}
+
+// toolName returns the name of the selected tool item
+func (m *menu) toolName() string {
+	return menuItems[m.item].name
+}
+
+// selectedColor returns the selected palette color
+func (m *menu) selectedColor() common.Color {
+	return m.palette[m.color]
+}
+
+// screen draws the current menu page
+func (m *menu) screen() [][]common.Color {
+	screen := make([][]common.Color, common.WindowSize)
+	for y := range screen {
+		screen[y] = make([]common.Color, common.WindowSize)
+	}
+
+	switch m.page {
+	case pageItems:
+		menuItems[m.item].icon.draw(screen)
+		// the indicator shows up to a line of items; it scrolls when the selected item is beyond the line
+		first := common.MaxInt(0, m.item-(common.WindowSize-1))
+		for i := first; i < len(menuItems) && i < first+common.WindowSize; i++ {
+			screen[menuIndicatorLine][i-first] = menuDimMarkColor
+		}
+		screen[menuIndicatorLine][m.item-first] = menuMarkColor
+
+	case pagePalette:
+		for x, c := range m.palette {
+			for y := 0; y < menuIndicatorLine-1; y++ {
+				screen[y][x] = c
+			}
+		}
+		screen[menuIndicatorLine][m.color] = menuMarkColor
+
+	case pageConfirmReset:
+		confirmIcon.draw(screen)
+		// underline the "no" or the "yes" half
+		start := 0
+		if m.confirm {
+			start = common.WindowSize / 2
+		}
+		for x := start; x < start+common.WindowSize/2; x++ {
+			screen[menuIndicatorLine][x] = menuMarkColor
+		}
+	}
+
+	return screen
+}
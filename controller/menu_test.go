@@ -0,0 +1,103 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+var _ = Describe("test the menu", func() {
+	var m *menu
+
+	palette := []common.Color{0xFF0000, 0x00FF00, 0x0000FF}
+
+	BeforeEach(func() {
+		m = newMenu(palette)
+		m.open()
+	})
+
+	// selectItem moves to the named item
+	selectItem := func(name string) {
+		for menuItems[m.item].name != name {
+			Expect(m.handle(hat.MoveRight)).Should(Equal(menuNone))
+		}
+	}
+
+	It("should move between the items", func() {
+		Expect(m.handle(hat.MoveLeft)).Should(Equal(menuNone))
+		Expect(menuItems[m.item].name).Should(Equal("save"))
+		Expect(m.handle(hat.MoveRight)).Should(Equal(menuNone))
+		Expect(menuItems[m.item].name).Should(Equal("pen"))
+
+		Expect(m.handle(hat.MoveDown)).Should(Equal(menuNone))
+		Expect(m.item).Should(Equal(0))
+
+		screen := m.screen()
+		Expect(screen[menuIndicatorLine][0]).Should(Equal(menuMarkColor))
+		Expect(screen[menuIndicatorLine][1]).Should(Equal(menuDimMarkColor))
+	})
+
+	It("should scroll the indicator with the selected item", func() {
+		Expect(len(menuItems)).Should(BeNumerically(">", common.WindowSize))
+
+		Expect(m.handle(hat.MoveLeft)).Should(Equal(menuNone))
+		screen := m.screen()
+		Expect(screen[menuIndicatorLine][common.WindowSize-1]).Should(Equal(menuMarkColor))
+		Expect(screen[menuIndicatorLine][0]).Should(Equal(menuDimMarkColor))
+
+		Expect(m.handle(hat.MoveRight)).Should(Equal(menuNone))
+		screen = m.screen()
+		Expect(screen[menuIndicatorLine][0]).Should(Equal(menuMarkColor))
+	})
+
+	DescribeTable("should select the tool", func(name string) {
+		selectItem(name)
+		Expect(m.handle(hat.Pressed)).Should(Equal(menuSetTool))
+		Expect(m.toolName()).Should(Equal(name))
+	},
+		Entry("pen", "pen"),
+		Entry("eraser", "eraser"),
+		Entry("bucket", "bucket"),
+	)
+
+	DescribeTable("should select the action", func(name string, expected menuCommand) {
+		selectItem(name)
+		Expect(m.handle(hat.Pressed)).Should(Equal(expected))
+	},
+		Entry("undo", "undo", menuUndo),
+		Entry("save", "save", menuSave),
+	)
+
+	It("should pick a color from the palette", func() {
+		selectItem("palette")
+		Expect(m.handle(hat.Pressed)).Should(Equal(menuNone))
+		Expect(m.page).Should(Equal(pagePalette))
+		Expect(m.screen()[0][1]).Should(Equal(common.Color(0x00FF00)))
+
+		Expect(m.handle(hat.MoveLeft)).Should(Equal(menuNone))
+		Expect(m.screen()[menuIndicatorLine][2]).Should(Equal(menuMarkColor))
+		Expect(m.handle(hat.Pressed)).Should(Equal(menuSetColor))
+		Expect(m.selectedColor()).Should(Equal(common.Color(0x0000FF)))
+
+		By("moving up goes back to the items")
+		Expect(m.handle(hat.MoveUp)).Should(Equal(menuNone))
+		Expect(m.page).Should(Equal(pageItems))
+	})
+
+	It("should reset only after confirmation", func() {
+		selectItem("reset")
+		Expect(m.handle(hat.Pressed)).Should(Equal(menuNone))
+		Expect(m.page).Should(Equal(pageConfirmReset))
+		Expect(m.screen()[menuIndicatorLine][0]).Should(Equal(menuMarkColor), `"no" is selected`)
+
+		Expect(m.handle(hat.Pressed)).Should(Equal(menuNone))
+		Expect(m.page).Should(Equal(pageItems))
+
+		Expect(m.handle(hat.Pressed)).Should(Equal(menuNone))
+		Expect(m.handle(hat.MoveRight)).Should(Equal(menuNone))
+		Expect(m.screen()[menuIndicatorLine][common.WindowSize-1]).Should(Equal(menuMarkColor))
+		Expect(m.handle(hat.Pressed)).Should(Equal(menuReset))
+	})
+})
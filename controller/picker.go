@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"math"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+type pickerPage int
+
+const (
+	pageHueSaturation pickerPage = iota
+	pageValue
+)
+
+// pickerSteps is the number of hues, saturations and values the picker offers
+const pickerSteps = common.WindowSize
+
+// picker is the HSV color picker. The first page is a grid of the hues (columns) and the saturations (rows, from full
+// saturation at the top to zero saturation at the bottom); the joystick moves the selector, and the press opens the
+// second page. The second page is a slider of the values (rows, from full value at the top to black at the bottom) of
+// the selected hue and saturation; the press selects the color, and moving left goes back to the first page.
+type picker struct {
+	page       pickerPage
+	hue        int
+	saturation int
+	value      int
+}
+
+func newPicker() *picker {
+	return &picker{}
+}
+
+// open shows the first page, and selects the closest color to c
+func (p *picker) open(c common.Color) {
+	hsv := c.HSV()
+
+	p.page = pageHueSaturation
+	p.hue = int(math.Round(hsv.H*pickerSteps/360)) % pickerSteps
+	p.saturation = toStep(1 - hsv.S)
+	p.value = toStep(1 - hsv.V)
+}
+
+// toStep converts [0, 1] to the closest step; the first step is 0, and the last step is 1
+func toStep(v float64) int {
+	return int(math.Round(v * (pickerSteps - 1)))
+}
+
+// handle handles a joystick event. It returns true when the color is selected.
+func (p *picker) handle(e hat.Event) bool {
+	if p.page == pageValue {
+		switch e {
+		case hat.MoveUp:
+			p.value = common.MaxInt(p.value-1, 0)
+		case hat.MoveDown:
+			p.value = common.MinInt(p.value+1, pickerSteps-1)
+		case hat.MoveLeft:
+			p.page = pageHueSaturation
+		case hat.Pressed:
+			return true
+		}
+		return false
+	}
+
+	switch e {
+	case hat.MoveLeft:
+		p.hue = (p.hue + pickerSteps - 1) % pickerSteps
+	case hat.MoveRight:
+		p.hue = (p.hue + 1) % pickerSteps
+	case hat.MoveUp:
+		p.saturation = common.MaxInt(p.saturation-1, 0)
+	case hat.MoveDown:
+		p.saturation = common.MinInt(p.saturation+1, pickerSteps-1)
+	case hat.Pressed:
+		p.page = pageValue
+	}
+	return false
+}
+
+// color returns the selected color
+func (p *picker) color() common.Color {
+	return p.hsv(p.hue, p.saturation, p.value).Color()
+}
+
+func (p *picker) hsv(hue, saturation, value int) common.HSV {
+	return common.HSV{
+		H: float64(hue) * 360 / pickerSteps,
+		S: 1 - float64(saturation)/(pickerSteps-1),
+		V: 1 - float64(value)/(pickerSteps-1),
+	}
+}
+
+// screen draws the current page. The hue and saturation grid is drawn in full value, so it's visible.
+func (p *picker) screen() [][]common.Color {
+	screen := make([][]common.Color, common.WindowSize)
+	for y := range screen {
+		screen[y] = make([]common.Color, common.WindowSize)
+		for x := range screen[y] {
+			if p.page == pageValue {
+				screen[y][x] = p.hsv(p.hue, p.saturation, y).Color()
+			} else {
+				screen[y][x] = p.hsv(x, y, 0).Color()
+			}
+		}
+	}
+
+	return screen
+}
+
+// cursor returns the display position of the selector
+func (p *picker) cursor() (uint8, uint8) {
+	if p.page == pageValue {
+		return uint8(p.hue), uint8(p.value)
+	}
+	return uint8(p.hue), uint8(p.saturation)
+}
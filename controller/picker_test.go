@@ -0,0 +1,100 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+	"github.com/nunnatsa/piHatDraw/state"
+)
+
+var _ = Describe("test the color picker", func() {
+	var p *picker
+
+	BeforeEach(func() {
+		p = newPicker()
+	})
+
+	DescribeTable("should select the closest color when opened", func(c common.Color, hue, saturation, value int) {
+		p.open(c)
+		Expect(p.page).Should(Equal(pageHueSaturation))
+		Expect(p.hue).Should(Equal(hue))
+		Expect(p.saturation).Should(Equal(saturation))
+		Expect(p.value).Should(Equal(value))
+	},
+		Entry("red", common.Color(0xFF0000), 0, 0, 0),
+		Entry("blue", common.Color(0x0000FF), 5, 0, 0),
+		Entry("dark pale green", common.Color(0x408040), 3, 4, 3),
+		Entry("white", common.Color(0xFFFFFF), 0, 7, 0),
+		Entry("black", common.Color(0), 0, 7, 7),
+	)
+
+	It("should draw the hue and saturation grid", func() {
+		p.open(0xFF0000)
+		screen := p.screen()
+		Expect(screen[0][0]).Should(Equal(common.Color(0xFF0000)))
+		Expect(screen[0][2]).Should(Equal(common.Color(0x80FF00)))
+		Expect(screen[4][0]).Should(Equal(common.Color(0xFF9292)))
+		Expect(screen[7][0]).Should(Equal(common.Color(0xFFFFFF)), "the last row is white")
+
+		x, y := p.cursor()
+		Expect(x).Should(BeEquivalentTo(0))
+		Expect(y).Should(BeEquivalentTo(0))
+	})
+
+	It("should select the color", func() {
+		p.open(0xFF0000)
+
+		Expect(p.handle(hat.MoveLeft)).Should(BeFalse())
+		Expect(p.hue).Should(Equal(7), "the hue wraps around")
+		Expect(p.handle(hat.MoveRight)).Should(BeFalse())
+		Expect(p.handle(hat.MoveRight)).Should(BeFalse())
+		Expect(p.handle(hat.MoveUp)).Should(BeFalse())
+		Expect(p.saturation).Should(Equal(0), "the saturation does not wrap")
+		Expect(p.handle(hat.MoveDown)).Should(BeFalse())
+
+		By("moving to the value page")
+		Expect(p.handle(hat.Pressed)).Should(BeFalse())
+		Expect(p.page).Should(Equal(pageValue))
+		Expect(p.screen()[0][5]).Should(Equal(p.screen()[0][0]), "each row is a single value")
+
+		Expect(p.handle(hat.MoveDown)).Should(BeFalse())
+		Expect(p.handle(hat.MoveDown)).Should(BeFalse())
+		x, y := p.cursor()
+		Expect(x).Should(BeEquivalentTo(1))
+		Expect(y).Should(BeEquivalentTo(2))
+
+		Expect(p.handle(hat.Pressed)).Should(BeTrue())
+		Expect(p.color()).Should(Equal(common.HSV{H: 45, S: 1 - 1.0/7, V: 1 - 2.0/7}.Color()))
+	})
+
+	DescribeTable("should pick the colors at the ends of the ranges", func(saturationSteps, valueSteps int, expected common.Color) {
+		p.open(0xFF0000)
+		for i := 0; i < saturationSteps; i++ {
+			p.handle(hat.MoveDown)
+		}
+		p.handle(hat.Pressed)
+		for i := 0; i < valueSteps; i++ {
+			p.handle(hat.MoveDown)
+		}
+		Expect(p.handle(hat.Pressed)).Should(BeTrue())
+		Expect(p.color()).Should(Equal(expected))
+
+		s := state.NewState(16, 16)
+		s.SetColor(0x00FF00)
+		Expect(s.SetColor(p.color())).ShouldNot(BeNil())
+		Expect(s.GetColor()).Should(Equal(expected))
+	},
+		Entry("white", pickerSteps-1, 0, common.Color(0xFFFFFF)),
+		Entry("grey", pickerSteps-1, 3, common.HSV{V: 4.0 / 7}.Color()),
+		Entry("black", 0, pickerSteps-1, common.Color(0)),
+	)
+
+	It("should go back to the hue and saturation page", func() {
+		p.open(0xFF0000)
+		p.handle(hat.Pressed)
+		Expect(p.handle(hat.MoveLeft)).Should(BeFalse())
+		Expect(p.page).Should(Equal(pageHueSaturation))
+	})
+})
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+// Plot styles
+const (
+	PlotBar  = "bar"
+	PlotLine = "line"
+)
+
+const (
+	DefaultPlotInterval = time.Second
+	minPlotInterval     = 100 * time.Millisecond
+)
+
+// sensorRanges are the default ranges of the sensors values; in Celsius, percent and hPa
+var sensorRanges = map[string][2]float64{
+	hat.SensorTemperature: {0, 40},
+	hat.SensorHumidity:    {0, 100},
+	hat.SensorPressure:    {950, 1050},
+}
+
+// DefaultPlotColors is the default color scale, from the minimum value to the maximum value
+var DefaultPlotColors = []common.Color{0x0000FF, 0x00FF00, 0xFFFF00, 0xFF0000}
+
+// PlotSettings is the sensor plot configuration
+type PlotSettings struct {
+	// Sensor is one of hat.SensorNames
+	Sensor string
+	// X, Y, Width and Height are the canvas region of the chart
+	X, Y          uint8
+	Width, Height uint8
+	// Style is PlotBar or PlotLine
+	Style string
+	// Min and Max are the values of the bottom and the top of the chart. When both are zero, the sensor default range
+	// is used.
+	Min, Max float64
+	// Colors is the color scale; the colors are spread evenly from Min to Max, and the values between them are
+	// interpolated
+	Colors []common.Color
+	// Interval is the time between two samples
+	Interval time.Duration
+}
+
+// withDefaults returns the settings, with the default values of the missing settings
+func (s PlotSettings) withDefaults() PlotSettings {
+	if s.Min == 0 && s.Max == 0 {
+		r := sensorRanges[s.Sensor]
+		s.Min, s.Max = r[0], r[1]
+	}
+
+	if len(s.Colors) == 0 {
+		s.Colors = DefaultPlotColors
+	}
+
+	if s.Style == "" {
+		s.Style = PlotBar
+	}
+
+	if s.Interval == 0 {
+		s.Interval = DefaultPlotInterval
+	}
+
+	return s
+}
+
+func (s PlotSettings) validate(canvasWidth, canvasHeight uint8) error {
+	if _, ok := sensorRanges[s.Sensor]; !ok {
+		return fmt.Errorf(`unknown sensor "%s"`, s.Sensor)
+	}
+
+	if s.Style != PlotBar && s.Style != PlotLine {
+		return fmt.Errorf(`unknown plot style "%s"; should be %s or %s`, s.Style, PlotBar, PlotLine)
+	}
+
+	if s.Width == 0 || s.Height == 0 || int(s.X)+int(s.Width) > int(canvasWidth) || int(s.Y)+int(s.Height) > int(canvasHeight) {
+		return fmt.Errorf("the plot region must be inside the %dX%d canvas", canvasWidth, canvasHeight)
+	}
+
+	if s.Min >= s.Max {
+		return fmt.Errorf("the minimum value must be less than the maximum value")
+	}
+
+	if s.Interval < minPlotInterval {
+		return fmt.Errorf("the minimum plot interval is %v", minPlotInterval)
+	}
+
+	return nil
+}
+
+// sensor reads a sensor value; see hat.EnvironmentSensor
+type sensor interface {
+	Read() (float64, error)
+}
+
+func openEnvironmentSensor(name string) (sensor, error) {
+	s, err := hat.OpenEnvironmentSensor(name)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// plot is a scrolling chart of a sensor. Each sample is a column, and the newest sample is on the right.
+type plot struct {
+	settings PlotSettings
+	sensor   sensor
+	samples  []float64
+	ticker   *time.Ticker
+}
+
+func newPlot(settings PlotSettings, s sensor) *plot {
+	return &plot{
+		settings: settings,
+		sensor:   s,
+		samples:  make([]float64, 0, settings.Width),
+		ticker:   time.NewTicker(settings.Interval),
+	}
+}
+
+func (p *plot) stop() {
+	p.ticker.Stop()
+}
+
+// sample reads the sensor, and scrolls the chart
+func (p *plot) sample() error {
+	value, err := p.sensor.Read()
+	if err != nil {
+		return err
+	}
+
+	if len(p.samples) == int(p.settings.Width) {
+		p.samples = append(p.samples[:0], p.samples[1:]...)
+	}
+	p.samples = append(p.samples, value)
+
+	return nil
+}
+
+// level returns the row of the value from the bottom of the chart; 0 is the minimum value or below
+func (p *plot) level(value float64) int {
+	n := (value - p.settings.Min) / (p.settings.Max - p.settings.Min)
+	n = math.Max(0, math.Min(1, n))
+	return int(math.Round(n * float64(p.settings.Height-1)))
+}
+
+// levelColor returns the color of the row level, from the color scale
+func (p *plot) levelColor(level int) common.Color {
+	colors := p.settings.Colors
+	if len(colors) == 1 || p.settings.Height == 1 {
+		return colors[len(colors)-1]
+	}
+
+	pos := float64(level) / float64(p.settings.Height-1) * float64(len(colors)-1)
+	i := int(pos)
+	if i >= len(colors)-1 {
+		return colors[len(colors)-1]
+	}
+
+	return interpolate(colors[i], colors[i+1], pos-float64(i))
+}
+
+// render draws the chart. The pixels without data are black.
+func (p *plot) render() [][]common.Color {
+	height := int(p.settings.Height)
+	region := make([][]common.Color, height)
+	for y := range region {
+		region[y] = make([]common.Color, p.settings.Width)
+	}
+
+	set := func(x, level int) {
+		region[height-1-level][x] = p.levelColor(level)
+	}
+
+	first := int(p.settings.Width) - len(p.samples)
+	for i, value := range p.samples {
+		x := first + i
+		level := p.level(value)
+
+		if p.settings.Style == PlotBar {
+			for l := 0; l <= level; l++ {
+				set(x, l)
+			}
+			continue
+		}
+
+		// connect the line to the previous sample
+		from := level
+		if i > 0 {
+			from = p.level(p.samples[i-1])
+		}
+		for l := common.MinInt(from, level); l <= common.MaxInt(from, level); l++ {
+			set(x, l)
+		}
+	}
+
+	return region
+}
+
+// interpolate returns the color at t between c1 (t = 0) and c2 (t = 1)
+func interpolate(c1, c2 common.Color, t float64) common.Color {
+	var res common.Color
+	for shift := 0; shift <= 16; shift += 8 {
+		v1 := float64((c1 >> shift) & 0xFF)
+		v2 := float64((c2 >> shift) & 0xFF)
+		res |= common.Color(math.Round(v1+(v2-v1)*t)) << shift
+	}
+	return res
+}
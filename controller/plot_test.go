@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+// fakeSensor returns its values one by one, and then fails
+type fakeSensor struct {
+	values []float64
+}
+
+func (s *fakeSensor) Read() (float64, error) {
+	if len(s.values) == 0 {
+		return 0, errors.New("no more values")
+	}
+
+	v := s.values[0]
+	s.values = s.values[1:]
+	return v, nil
+}
+
+var _ = Describe("test the sensor plot", func() {
+	settings := PlotSettings{
+		Sensor: hat.SensorTemperature,
+		Width:  3,
+		Height: 4,
+		Min:    0,
+		Max:    30,
+		Colors: []common.Color{0x0000FF, 0xFF0000},
+	}.withDefaults()
+
+	const (
+		b = common.Color(0x0000FF)
+		r = common.Color(0xFF0000)
+		// the colors of the middle levels
+		l1 = common.Color(0x5500AA)
+		l2 = common.Color(0xAA0055)
+	)
+
+	It("should set the defaults", func() {
+		s := PlotSettings{Sensor: hat.SensorPressure}.withDefaults()
+		Expect(s.Min).Should(BeEquivalentTo(950))
+		Expect(s.Max).Should(BeEquivalentTo(1050))
+		Expect(s.Style).Should(Equal(PlotBar))
+		Expect(s.Colors).Should(Equal(DefaultPlotColors))
+		Expect(s.Interval).Should(Equal(DefaultPlotInterval))
+	})
+
+	DescribeTable("should validate the settings", func(modify func(s *PlotSettings), valid bool) {
+		s := settings
+		s.X, s.Y = 5, 6
+		modify(&s)
+
+		err := s.validate(8, 10)
+		if valid {
+			Expect(err).ShouldNot(HaveOccurred())
+		} else {
+			Expect(err).Should(HaveOccurred())
+		}
+	},
+		Entry("valid", func(s *PlotSettings) {}, true),
+		Entry("unknown sensor", func(s *PlotSettings) { s.Sensor = "light" }, false),
+		Entry("unknown style", func(s *PlotSettings) { s.Style = "pie" }, false),
+		Entry("outside the canvas", func(s *PlotSettings) { s.X = 6 }, false),
+		Entry("empty region", func(s *PlotSettings) { s.Width = 0 }, false),
+		Entry("wrong range", func(s *PlotSettings) { s.Min = 30 }, false),
+		Entry("too short interval", func(s *PlotSettings) { s.Interval = time.Millisecond }, false),
+	)
+
+	It("should scroll the bar chart", func() {
+		p := newPlot(settings, &fakeSensor{values: []float64{30, 10, 0, -5}})
+		defer p.stop()
+
+		Expect(p.sample()).To(Succeed())
+		Expect(p.render()).Should(Equal([][]common.Color{
+			{0, 0, r},
+			{0, 0, l2},
+			{0, 0, l1},
+			{0, 0, b},
+		}))
+
+		Expect(p.sample()).To(Succeed())
+		Expect(p.sample()).To(Succeed())
+		Expect(p.render()).Should(Equal([][]common.Color{
+			{r, 0, 0},
+			{l2, 0, 0},
+			{l1, l1, 0},
+			{b, b, b},
+		}))
+
+		By("scrolling the oldest sample out, and clamping the value below the minimum")
+		Expect(p.sample()).To(Succeed())
+		Expect(p.render()).Should(Equal([][]common.Color{
+			{0, 0, 0},
+			{0, 0, 0},
+			{l1, 0, 0},
+			{b, b, b},
+		}))
+
+		Expect(p.sample()).ShouldNot(Succeed())
+	})
+
+	It("should connect the samples of the line chart", func() {
+		s := settings
+		s.Style = PlotLine
+		p := newPlot(s, &fakeSensor{values: []float64{0, 30, 20}})
+		defer p.stop()
+
+		for i := 0; i < 3; i++ {
+			Expect(p.sample()).To(Succeed())
+		}
+
+		Expect(p.render()).Should(Equal([][]common.Color{
+			{0, r, r},
+			{0, l2, l2},
+			{0, l1, 0},
+			{b, b, 0},
+		}))
+	})
+
+	It("should interpolate the colors", func() {
+		Expect(interpolate(0x000000, 0xFFFFFF, 0.5)).Should(Equal(common.Color(0x808080)))
+		Expect(interpolate(0xFF0000, 0x00FF00, 0)).Should(Equal(common.Color(0xFF0000)))
+		Expect(interpolate(0xFF0000, 0x00FF00, 1)).Should(Equal(common.Color(0x00FF00)))
+	})
+})
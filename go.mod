@@ -4,9 +4,9 @@ go 1.20
 
 require (
 	github.com/gorilla/websocket v1.4.2
-	github.com/nathany/bobblehat v0.0.0-20170421151738-14b0d1b4643e
 	github.com/onsi/ginkgo/v2 v2.10.0
 	github.com/onsi/gomega v1.27.8
+	golang.org/x/term v0.9.0
 )
 
 require (
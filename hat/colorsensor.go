@@ -0,0 +1,75 @@
+package hat
+
+import (
+	"math"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// colorSensorName is the IIO name of the Sense HAT v2 TCS34725 color sensor
+const colorSensorName = "tcs3472"
+
+// RGBC is a color sensor reading; the raw red, green, blue and clear (unfiltered) channels
+type RGBC struct {
+	R, G, B, C uint16
+}
+
+// ColorSensor reads the color of the object in front of the sensor
+type ColorSensor interface {
+	ReadRGBC() (RGBC, error)
+}
+
+// TCS34725 reads the Sense HAT v2 color sensor from the Linux Industrial I/O sysfs interface
+type TCS34725 struct {
+	dir string
+}
+
+// OpenColorSensor finds the Sense HAT v2 color sensor
+func OpenColorSensor() (*TCS34725, error) {
+	dir, err := findIIODevice(colorSensorName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TCS34725{dir: dir}, nil
+}
+
+func (s *TCS34725) ReadRGBC() (RGBC, error) {
+	var channels [4]uint16
+	for i, channel := range []string{"red", "green", "blue", "clear"} {
+		raw, err := readIIOValue(s.dir, "in_intensity_"+channel+"_raw")
+		if err != nil {
+			return RGBC{}, err
+		}
+		channels[i] = uint16(raw)
+	}
+
+	return RGBC{R: channels[0], G: channels[1], B: channels[2], C: channels[3]}, nil
+}
+
+// Color converts the reading to a color, using white, the reading of a white object, as the white balance: each
+// channel is divided by its white channel, so a white object is white. The brightness is the clear channel relative
+// to the white clear channel. Without a white balance (a zero white), the channels are used as is, and the color is
+// at full brightness.
+func (r RGBC) Color(white RGBC) common.Color {
+	rgb := [3]float64{float64(r.R), float64(r.G), float64(r.B)}
+	brightness := 1.0
+
+	if white != (RGBC{}) {
+		for i, w := range []uint16{white.R, white.G, white.B} {
+			rgb[i] /= math.Max(float64(w), 1)
+		}
+		brightness = math.Min(1, float64(r.C)/math.Max(float64(white.C), 1))
+	}
+
+	brightest := math.Max(rgb[0], math.Max(rgb[1], rgb[2]))
+	if brightest == 0 {
+		return 0
+	}
+
+	var c common.Color
+	for _, v := range rgb {
+		c = c<<8 | common.Color(math.Round(v/brightest*brightness*0xFF))
+	}
+	return c
+}
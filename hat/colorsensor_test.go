@@ -0,0 +1,53 @@
+package hat
+
+import (
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test the color sensor", func() {
+	Context("test the TCS34725", func() {
+		origFunc := getIIODevicesPath
+
+		AfterEach(func() {
+			getIIODevicesPath = origFunc
+		})
+
+		It("should read the raw channels", func() {
+			getIIODevicesPath = func() string {
+				return path.Join(getTestFileLocation(), "iio")
+			}
+
+			s, err := OpenColorSensor()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(s.dir).Should(HaveSuffix("iio:device4"))
+
+			reading, err := s.ReadRGBC()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(reading).Should(Equal(RGBC{R: 300, G: 150, B: 75, C: 500}))
+		})
+
+		It("should return error if the color sensor is not found", func() {
+			getIIODevicesPath = func() string {
+				return path.Join(getTestFileLocation(), "graphics")
+			}
+
+			_, err := OpenColorSensor()
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	DescribeTable("should convert the reading to a color", func(reading, white RGBC, expected common.Color) {
+		Expect(reading.Color(white)).Should(Equal(expected))
+	},
+		Entry("without white balance, in full brightness", RGBC{R: 300, G: 150, B: 75, C: 500}, RGBC{}, common.Color(0xFF8040)),
+		Entry("with the white balance of the channels", RGBC{R: 300, G: 150, B: 75, C: 500}, RGBC{R: 300, G: 300, B: 300, C: 500}, common.Color(0xFF8040)),
+		Entry("a gray object, that reflects half of the light", RGBC{R: 300, G: 150, B: 75, C: 500}, RGBC{R: 600, G: 300, B: 150, C: 1000}, common.Color(0x808080)),
+		Entry("brighter than white", RGBC{R: 300, G: 300, B: 300, C: 2000}, RGBC{R: 300, G: 300, B: 300, C: 1000}, common.Color(0xFFFFFF)),
+		Entry("no light", RGBC{}, RGBC{R: 300, G: 300, B: 300, C: 1000}, common.Color(0)),
+	)
+})
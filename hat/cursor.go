@@ -0,0 +1,162 @@
+package hat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// Cursor styles
+const (
+	// CursorInvert inverts the color of the cursor pixel
+	CursorInvert = "invert"
+	// CursorColor displays the cursor pixel in a fixed color
+	CursorColor = "color"
+	// CursorCrosshair tints the row and the column of the cursor, and keeps the color of the cursor pixel
+	CursorCrosshair = "crosshair"
+	// CursorContrast displays the cursor pixel in black or white, whichever is more visible on the pixel color
+	CursorContrast = "contrast"
+)
+
+const blinkInterval = 500 * time.Millisecond
+
+// CursorSettings is how the cursor is displayed
+type CursorSettings struct {
+	Style string `json:"style"`
+	// Color is the color of the color style, and the tint of the crosshair style
+	Color common.Color `json:"color"`
+	// Blink turns the cursor on and off
+	Blink bool `json:"blink"`
+}
+
+func NewCursorSettings() CursorSettings {
+	return CursorSettings{
+		Style: CursorInvert,
+		Color: 0xFFFFFF,
+	}
+}
+
+func (s CursorSettings) Validate() error {
+	switch s.Style {
+	case CursorInvert, CursorColor, CursorCrosshair, CursorContrast:
+		return nil
+	}
+
+	return fmt.Errorf(`unknown cursor style "%s"; should be one of %s, %s, %s or %s`, s.Style, CursorInvert, CursorColor, CursorCrosshair, CursorContrast)
+}
+
+// cursorSettings returns the cursor settings of the message, or the default settings if the message has no settings
+func (m DisplayMessage) cursorSettings() CursorSettings {
+	if m.Settings == nil {
+		return NewCursorSettings()
+	}
+	return m.Settings.Cursor
+}
+
+// blinking returns true if the message has a blinking cursor
+func (m DisplayMessage) blinking() bool {
+	return !m.NoCursor && m.cursorSettings().Blink
+}
+
+// pixelColor returns the color of the display pixel at x, y with the cursor. When cursorOn is false, the blinking
+// cursor is off, and the pixel color is returned as is, as well as when the message has no cursor.
+func (m DisplayMessage) pixelColor(x, y int, cursorOn bool) common.Color {
+	c := m.Screen[y][x]
+	if !cursorOn || m.NoCursor {
+		return c
+	}
+
+	cursor := m.cursorSettings()
+	isCursor := m.isCursor(x, y)
+
+	switch cursor.Style {
+	case CursorColor:
+		if isCursor {
+			return cursor.Color
+		}
+
+	case CursorCrosshair:
+		size := m.cursorSize()
+		inRow := y >= int(m.CursorY) && y < int(m.CursorY)+size
+		inColumn := x >= int(m.CursorX) && x < int(m.CursorX)+size
+		if !isCursor && (inRow || inColumn) {
+			return blend(c, cursor.Color)
+		}
+
+	case CursorContrast:
+		if isCursor {
+			return contrastColor(c)
+		}
+
+	default:
+		if isCursor {
+			return reversColor(c)
+		}
+	}
+
+	return c
+}
+
+// blend mixes two colors evenly
+func blend(c1, c2 common.Color) common.Color {
+	return (c1>>1)&0x7F7F7F + (c2>>1)&0x7F7F7F
+}
+
+// contrastColor returns black for light colors and white for dark colors, using the ITU-R BT.601 luma
+func contrastColor(c common.Color) common.Color {
+	r := uint32(c>>16) & 0xFF
+	g := uint32(c>>8) & 0xFF
+	b := uint32(c) & 0xFF
+
+	if 299*r+587*g+114*b >= 128*1000 {
+		return 0
+	}
+	return 0xFFFFFF
+}
+
+func reversColor(c common.Color) common.Color {
+	return c ^ 0xFFFFFF
+}
+
+// blinker turns the cursor on and off, while the displayed message has a blinking cursor. When the cursor is not
+// blinking, its channel is nil.
+type blinker struct {
+	ticker *time.Ticker
+	c      <-chan time.Time
+	on     bool
+}
+
+func newBlinker() *blinker {
+	return &blinker{on: true}
+}
+
+// reset turns the cursor on, and starts or stops blinking. The cursor stays on for a full interval, so it's visible
+// while it's moving.
+func (b *blinker) reset(blink bool) {
+	b.on = true
+
+	if !blink {
+		b.stop()
+		return
+	}
+
+	if b.ticker == nil {
+		b.ticker = time.NewTicker(blinkInterval)
+		b.c = b.ticker.C
+	} else {
+		b.ticker.Reset(blinkInterval)
+	}
+}
+
+func (b *blinker) toggle() {
+	b.on = !b.on
+}
+
+func (b *blinker) stop() {
+	if b.ticker != nil {
+		b.ticker.Stop()
+		b.ticker = nil
+	}
+	b.c = nil
+}
@@ -0,0 +1,131 @@
+package hat
+
+import (
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test the cursor", func() {
+	newMessage := func(cursor CursorSettings) DisplayMessage {
+		msg := NewDisplayMessage(newTestScreen(0x404040), 2, 3)
+		settings := NewDisplaySettings()
+		settings.Cursor = cursor
+		msg.Settings = &settings
+		return msg
+	}
+
+	Context("test pixelColor", func() {
+		It("should invert the cursor by default", func() {
+			msg := NewDisplayMessage(newTestScreen(0x404040), 2, 3)
+			Expect(msg.pixelColor(2, 3, true)).Should(Equal(common.Color(0xBFBFBF)))
+			Expect(msg.pixelColor(3, 3, true)).Should(Equal(common.Color(0x404040)))
+		})
+
+		It("should use the fixed color", func() {
+			msg := newMessage(CursorSettings{Style: CursorColor, Color: 0x00FF00})
+			Expect(msg.pixelColor(2, 3, true)).Should(Equal(common.Color(0x00FF00)))
+			Expect(msg.pixelColor(2, 4, true)).Should(Equal(common.Color(0x404040)))
+		})
+
+		It("should tint the row and the column of the crosshair", func() {
+			msg := newMessage(CursorSettings{Style: CursorCrosshair, Color: 0xFF0000})
+			Expect(msg.pixelColor(2, 3, true)).Should(Equal(common.Color(0x404040)), "the cursor keeps its color")
+			Expect(msg.pixelColor(7, 3, true)).Should(Equal(common.Color(0x9F2020)))
+			Expect(msg.pixelColor(2, 0, true)).Should(Equal(common.Color(0x9F2020)))
+			Expect(msg.pixelColor(3, 4, true)).Should(Equal(common.Color(0x404040)))
+		})
+
+		It("should tint the rows and the columns of a zoomed cursor", func() {
+			msg := newMessage(CursorSettings{Style: CursorCrosshair, Color: 0xFF0000})
+			msg.Zoom = 2
+			Expect(msg.pixelColor(3, 4, true)).Should(Equal(common.Color(0x404040)))
+			Expect(msg.pixelColor(0, 4, true)).Should(Equal(common.Color(0x9F2020)))
+			Expect(msg.pixelColor(3, 7, true)).Should(Equal(common.Color(0x9F2020)))
+			Expect(msg.pixelColor(4, 5, true)).Should(Equal(common.Color(0x404040)))
+		})
+
+		It("should pick a contrasting color", func() {
+			msg := newMessage(CursorSettings{Style: CursorContrast})
+			Expect(msg.pixelColor(2, 3, true)).Should(Equal(common.Color(0xFFFFFF)))
+
+			msg.Screen[3][2] = 0x00FF00
+			Expect(msg.pixelColor(2, 3, true)).Should(Equal(common.Color(0)))
+		})
+
+		It("should hide the cursor when it is off", func() {
+			msg := newMessage(CursorSettings{Style: CursorCrosshair, Color: 0xFF0000})
+			Expect(msg.pixelColor(7, 3, false)).Should(Equal(common.Color(0x404040)))
+		})
+	})
+
+	DescribeTable("should pick black or white by the luminance", func(c, expected common.Color) {
+		Expect(contrastColor(c)).Should(Equal(expected))
+	},
+		Entry("black", common.Color(0), common.Color(0xFFFFFF)),
+		Entry("white", common.Color(0xFFFFFF), common.Color(0)),
+		Entry("mid grey", common.Color(0x808080), common.Color(0)),
+		Entry("dark grey", common.Color(0x7F7F7F), common.Color(0xFFFFFF)),
+		Entry("blue", common.Color(0x0000FF), common.Color(0xFFFFFF)),
+		Entry("yellow", common.Color(0xFFFF00), common.Color(0)),
+	)
+
+	It("should validate the cursor style", func() {
+		Expect(NewCursorSettings().Validate()).Should(Succeed())
+		Expect(CursorSettings{Style: "underline"}.Validate()).ShouldNot(Succeed())
+
+		settings := NewDisplaySettings()
+		settings.Cursor.Style = ""
+		Expect(settings.Validate()).ShouldNot(Succeed())
+	})
+
+	It("should blink", func() {
+		b := newBlinker()
+		Expect(b.on).Should(BeTrue())
+		Expect(b.c).Should(BeNil())
+
+		b.reset(true)
+		Expect(b.c).ShouldNot(BeNil())
+		Eventually(b.c, blinkInterval*2).Should(Receive())
+
+		b.toggle()
+		Expect(b.on).Should(BeFalse())
+
+		b.reset(true)
+		Expect(b.on).Should(BeTrue())
+
+		b.reset(false)
+		Expect(b.c).Should(BeNil())
+		Expect(b.ticker).Should(BeNil())
+	})
+
+	It("should redraw the blinking cursor", func() {
+		je := make(chan Event, 1)
+		se := make(chan DisplayMessage, 1)
+		out := &syncBuffer{}
+
+		t := NewTerminal(je, se)
+		t.in = &blockingReader{done: t.done}
+		t.out = out
+		t.Start()
+		defer t.Stop()
+
+		msg := newMessage(CursorSettings{Style: CursorColor, Color: 0xFF0000, Blink: true})
+		se <- msg
+		Eventually(out.String).Should(ContainSubstring(renderTerminalFrame(msg, true)))
+		Eventually(out.String, blinkInterval*3).Should(ContainSubstring(renderTerminalFrame(msg, false)))
+	})
+})
+
+// blockingReader blocks until done is closed
+type blockingReader struct {
+	done chan struct{}
+}
+
+func (r *blockingReader) Read([]byte) (int, error) {
+	<-r.done
+	return 0, io.EOF
+}
@@ -0,0 +1,193 @@
+package hat
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+const (
+	gammaTableSize = 32
+	maxGammaValue  = 31
+	maxBrightness  = 100
+)
+
+// GammaTable maps each 5-bit color channel value to the value the LED is set to. The 6-bit green channel is mapped
+// using its 5 MS bits, and keeps its LS bit.
+type GammaTable [gammaTableSize]uint8
+
+// DefaultGammaTable keeps the colors as is
+var DefaultGammaTable = GammaTable{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
+}
+
+// LowLightGammaTable is the Sense HAT low light gamma table, for dim rooms
+var LowLightGammaTable = GammaTable{
+	0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 2, 2,
+	3, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 10, 10,
+}
+
+func (t *GammaTable) UnmarshalJSON(bt []byte) error {
+	var values []uint8
+	if err := json.Unmarshal(bt, &values); err != nil {
+		return err
+	}
+
+	if len(values) != gammaTableSize {
+		return fmt.Errorf("the gamma table must contain %d values", gammaTableSize)
+	}
+
+	copy(t[:], values)
+	return nil
+}
+
+// Dithering modes
+const (
+	DitherNone   = "none"
+	DitherBayer2 = "bayer2"
+	DitherBayer4 = "bayer4"
+)
+
+// the Bayer threshold matrices of the dithering modes. The truncated part of a channel is at most 3 bits, so a larger
+// matrix would not add more levels.
+var bayerMatrices = map[string][][]uint8{
+	DitherBayer2: bayerMatrix(2),
+	DitherBayer4: bayerMatrix(4),
+}
+
+// Overview downsampling methods
+const (
+	DownsampleAverage  = "average"
+	DownsampleMajority = "majority"
+)
+
+// DisplaySettings is the color management of the LED matrix
+type DisplaySettings struct {
+	Gamma GammaTable `json:"gamma"`
+	// Brightness is in percent
+	Brightness uint8 `json:"brightness"`
+	// LowLight replaces the gamma table with the low light gamma table
+	LowLight bool `json:"lowLight"`
+	// Dithering is the dithering mode used when converting the colors to the HAT colors
+	Dithering string `json:"dithering"`
+	// Downsample is how the canvas is downsampled to the LED matrix, in the overview mode
+	Downsample string `json:"downsample"`
+	// Cursor is how the cursor is displayed
+	Cursor CursorSettings `json:"cursor"`
+}
+
+func NewDisplaySettings() DisplaySettings {
+	return DisplaySettings{
+		Gamma:      DefaultGammaTable,
+		Brightness: maxBrightness,
+		Dithering:  DitherNone,
+		Downsample: DownsampleAverage,
+		Cursor:     NewCursorSettings(),
+	}
+}
+
+func (s DisplaySettings) Validate() error {
+	if s.Brightness > maxBrightness {
+		return fmt.Errorf("brightness must be between 0 and %d", maxBrightness)
+	}
+
+	for _, v := range s.Gamma {
+		if v > maxGammaValue {
+			return fmt.Errorf("gamma table values must be between 0 and %d", maxGammaValue)
+		}
+	}
+
+	if _, ok := bayerMatrices[s.Dithering]; !ok && s.Dithering != DitherNone {
+		return fmt.Errorf(`unknown dithering mode "%s"`, s.Dithering)
+	}
+
+	if s.Downsample != DownsampleAverage && s.Downsample != DownsampleMajority {
+		return fmt.Errorf(`unknown downsample method "%s"; should be %s or %s`, s.Downsample, DownsampleAverage, DownsampleMajority)
+	}
+
+	if err := s.Cursor.Validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// convert converts the color of the canvas pixel at x, y to the HAT color: the brightness and the dithering are
+// applied on the 24-bit color, and the gamma table on the 16-bit color. Without settings, the color is only converted.
+func (s *DisplaySettings) convert(c common.Color, x, y int) hatColor {
+	if s == nil {
+		return toHatColor(c)
+	}
+
+	if s.Brightness < maxBrightness {
+		c = dim(c, s.Brightness)
+	}
+
+	if matrix, ok := bayerMatrices[s.Dithering]; ok {
+		c = dither(c, matrix, x, y)
+	}
+
+	gamma := &s.Gamma
+	if s.LowLight {
+		gamma = &LowLightGammaTable
+	}
+
+	hc := toHatColor(c)
+	r := hatColor(gamma[(hc>>11)&0x1F])
+	g := hatColor(gamma[(hc>>6)&0x1F])<<1 | (hc>>5)&1
+	b := hatColor(gamma[hc&0x1F])
+
+	return r<<11 | g<<5 | b
+}
+
+// dim scales each channel of the color by percent
+func dim(c common.Color, percent uint8) common.Color {
+	r := ((c >> 16) & 0xFF) * common.Color(percent) / maxBrightness
+	g := ((c >> 8) & 0xFF) * common.Color(percent) / maxBrightness
+	b := (c & 0xFF) * common.Color(percent) / maxBrightness
+
+	return r<<16 | g<<8 | b
+}
+
+// dither adds the threshold of the pixel to each channel, before the channels are truncated to 5 or 6 bits. The
+// threshold is scaled to the truncated part of the channel, so the average of the pattern is the original color.
+func dither(c common.Color, matrix [][]uint8, x, y int) common.Color {
+	size := len(matrix)
+	threshold := uint32(matrix[y%size][x%size])
+	levels := uint32(size * size)
+
+	ditherChannel := func(v uint32, step uint32) common.Color {
+		v += threshold * step / levels
+		if v > 0xFF {
+			v = 0xFF
+		}
+		return common.Color(v)
+	}
+
+	r := ditherChannel(uint32(c>>16)&0xFF, 1<<3)
+	g := ditherChannel(uint32(c>>8)&0xFF, 1<<2)
+	b := ditherChannel(uint32(c)&0xFF, 1<<3)
+
+	return r<<16 | g<<8 | b
+}
+
+// bayerMatrix builds the size X size Bayer threshold matrix, where size is a power of 2
+func bayerMatrix(size int) [][]uint8 {
+	matrix := [][]uint8{{0}}
+	for n := 1; n < size; n *= 2 {
+		next := make([][]uint8, 2*n)
+		for y := range next {
+			next[y] = make([]uint8, 2*n)
+			for x := range next[y] {
+				// each quadrant is the previous matrix, multiplied by 4, with the offset of the quadrant
+				quadrantOffset := [2][2]uint8{{0, 2}, {3, 1}}[y/n][x/n]
+				next[y][x] = 4*matrix[y%n][x%n] + quadrantOffset
+			}
+		}
+		matrix = next
+	}
+
+	return matrix
+}
@@ -0,0 +1,220 @@
+package hat
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test the display settings", func() {
+	Context("test convert", func() {
+		It("should only convert the color without settings", func() {
+			var s *DisplaySettings
+			Expect(s.convert(0xFFFFFF, 0, 0)).Should(BeEquivalentTo(0xFFFF))
+			Expect(s.convert(0x123456, 0, 0)).Should(Equal(toHatColor(0x123456)))
+		})
+
+		It("should keep the colors with the default settings", func() {
+			s := NewDisplaySettings()
+			for _, c := range []common.Color{0, 0xFFFFFF, 0x123456, 0x0C0804, 0xF800F8} {
+				Expect(s.convert(c, 0, 0)).Should(Equal(toHatColor(c)))
+			}
+		})
+
+		It("should apply the brightness", func() {
+			s := NewDisplaySettings()
+			s.Brightness = 50
+			Expect(s.convert(0xFFFFFF, 0, 0)).Should(Equal(toHatColor(0x7F7F7F)))
+
+			s.Brightness = 0
+			Expect(s.convert(0xFFFFFF, 0, 0)).Should(BeEquivalentTo(0))
+		})
+
+		It("should apply the gamma table", func() {
+			s := NewDisplaySettings()
+			for i := range s.Gamma {
+				s.Gamma[i] = uint8(i / 2)
+			}
+
+			Expect(s.convert(0xFFFFFF, 0, 0)).Should(BeEquivalentTo(0b01111_011111_01111))
+			Expect(s.convert(0x080008, 0, 0)).Should(BeEquivalentTo(0))
+		})
+
+		It("should use the low light gamma table", func() {
+			s := NewDisplaySettings()
+			s.LowLight = true
+
+			Expect(s.convert(0xFFFFFF, 0, 0)).Should(BeEquivalentTo(0b01010_010101_01010))
+			Expect(s.convert(0x080808, 0, 0)).Should(BeEquivalentTo(0b00001_000010_00001))
+			Expect(s.convert(0, 0, 0)).Should(BeEquivalentTo(0))
+		})
+	})
+
+	Context("test dithering", func() {
+		// 0x838185 is between the HAT colors; the red is 3/8 of the way to the next level, the green 1/4 and the
+		// blue 5/8
+		const clr = common.Color(0x838185)
+
+		DescribeTable("should match the golden frame", func(mode string, expected frame) {
+			s := NewDisplaySettings()
+			s.Dithering = mode
+			Expect(s.Validate()).To(Succeed())
+
+			msg := NewDisplayMessage(newTestScreen(clr), 0, 0)
+			msg.Settings = &s
+
+			Expect(convertFrame(msg)).Should(Equal(expected))
+		},
+			Entry("none", DitherNone, frame{
+				{0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410},
+				{0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410},
+				{0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410},
+				{0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410},
+				{0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410},
+				{0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410},
+				{0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410},
+				{0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410, 0x8410},
+			}),
+			Entry("bayer2", DitherBayer2, frame{
+				{0x8410, 0x8411, 0x8410, 0x8411, 0x8410, 0x8411, 0x8410, 0x8411},
+				{0x8C31, 0x8410, 0x8C31, 0x8410, 0x8C31, 0x8410, 0x8C31, 0x8410},
+				{0x8410, 0x8411, 0x8410, 0x8411, 0x8410, 0x8411, 0x8410, 0x8411},
+				{0x8C31, 0x8410, 0x8C31, 0x8410, 0x8C31, 0x8410, 0x8C31, 0x8410},
+				{0x8410, 0x8411, 0x8410, 0x8411, 0x8410, 0x8411, 0x8410, 0x8411},
+				{0x8C31, 0x8410, 0x8C31, 0x8410, 0x8C31, 0x8410, 0x8C31, 0x8410},
+				{0x8410, 0x8411, 0x8410, 0x8411, 0x8410, 0x8411, 0x8410, 0x8411},
+				{0x8C31, 0x8410, 0x8C31, 0x8410, 0x8C31, 0x8410, 0x8C31, 0x8410},
+			}),
+			Entry("bayer4", DitherBayer4, frame{
+				{0x8410, 0x8411, 0x8410, 0x8C11, 0x8410, 0x8411, 0x8410, 0x8C11},
+				{0x8C31, 0x8410, 0x8C31, 0x8411, 0x8C31, 0x8410, 0x8C31, 0x8411},
+				{0x8410, 0x8C11, 0x8410, 0x8411, 0x8410, 0x8C11, 0x8410, 0x8411},
+				{0x8C31, 0x8411, 0x8C31, 0x8410, 0x8C31, 0x8411, 0x8C31, 0x8410},
+				{0x8410, 0x8411, 0x8410, 0x8C11, 0x8410, 0x8411, 0x8410, 0x8C11},
+				{0x8C31, 0x8410, 0x8C31, 0x8411, 0x8C31, 0x8410, 0x8C31, 0x8411},
+				{0x8410, 0x8C11, 0x8410, 0x8411, 0x8410, 0x8C11, 0x8410, 0x8411},
+				{0x8C31, 0x8411, 0x8C31, 0x8410, 0x8C31, 0x8411, 0x8C31, 0x8410},
+			}),
+		)
+
+		It("should keep the pattern on the canvas when the window is moved", func() {
+			s := NewDisplaySettings()
+			s.Dithering = DitherBayer4
+
+			msg := NewDisplayMessage(newTestScreen(clr), 0, 0)
+			msg.Settings = &s
+			orig := convertFrame(msg)
+
+			msg.WindowX = 1
+			msg.WindowY = 2
+			moved := convertFrame(msg)
+
+			for y := 0; y < 6; y++ {
+				for x := 0; x < 7; x++ {
+					Expect(moved[y][x]).Should(Equal(orig[y+2][x+1]))
+				}
+			}
+		})
+
+		It("should keep the pattern on the canvas when the zoomed window is moved", func() {
+			s := NewDisplaySettings()
+			s.Dithering = DitherBayer4
+
+			msg := NewDisplayMessage(newTestScreen(clr), 0, 0)
+			msg.Settings = &s
+			msg.Zoom = 2
+			orig := convertFrame(msg)
+
+			By("moving the window one canvas pixel, the image moves two LEDs")
+			msg.WindowX = 1
+			msg.WindowY = 1
+			moved := convertFrame(msg)
+
+			for y := 0; y < 6; y++ {
+				for x := 0; x < 6; x++ {
+					Expect(moved[y][x]).Should(Equal(orig[y+2][x+2]))
+				}
+			}
+		})
+
+		It("should build the Bayer matrices", func() {
+			Expect(bayerMatrix(2)).Should(Equal([][]uint8{{0, 2}, {3, 1}}))
+			Expect(bayerMatrix(4)).Should(Equal([][]uint8{
+				{0, 8, 2, 10},
+				{12, 4, 14, 6},
+				{3, 11, 1, 9},
+				{15, 7, 13, 5},
+			}))
+		})
+
+		It("should not overflow", func() {
+			s := NewDisplaySettings()
+			s.Dithering = DitherBayer4
+			for y := 0; y < 4; y++ {
+				for x := 0; x < 4; x++ {
+					Expect(s.convert(0xFFFFFF, x, y)).Should(BeEquivalentTo(0xFFFF))
+				}
+			}
+		})
+	})
+
+	Context("test Validate", func() {
+		It("should accept the default settings", func() {
+			Expect(NewDisplaySettings().Validate()).To(Succeed())
+		})
+
+		It("should reject too high brightness", func() {
+			s := NewDisplaySettings()
+			s.Brightness = 101
+			Expect(s.Validate()).ShouldNot(Succeed())
+		})
+
+		It("should reject unknown dithering mode", func() {
+			s := NewDisplaySettings()
+			s.Dithering = "random"
+			Expect(s.Validate()).ShouldNot(Succeed())
+		})
+
+		It("should reject unknown downsample method", func() {
+			s := NewDisplaySettings()
+			s.Downsample = "median"
+			Expect(s.Validate()).ShouldNot(Succeed())
+		})
+
+		It("should reject too high gamma values", func() {
+			s := NewDisplaySettings()
+			s.Gamma[3] = 32
+			Expect(s.Validate()).ShouldNot(Succeed())
+		})
+	})
+
+	Context("test GammaTable JSON", func() {
+		It("should marshal and unmarshal the table", func() {
+			js, err := json.Marshal(LowLightGammaTable)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(js)).Should(HavePrefix("[0,1,1,"))
+
+			var t GammaTable
+			Expect(json.Unmarshal(js, &t)).To(Succeed())
+			Expect(t).Should(Equal(LowLightGammaTable))
+		})
+
+		It("should reject wrong table size", func() {
+			var t GammaTable
+			Expect(json.Unmarshal([]byte("[1, 2, 3]"), &t)).ShouldNot(Succeed())
+		})
+	})
+})
+
+func convertFrame(msg DisplayMessage) frame {
+	var f frame
+	for y := 0; y < common.WindowSize; y++ {
+		for x := 0; x < common.WindowSize; x++ {
+			f[y][x] = msg.convert(msg.Screen[y][x], x, y)
+		}
+	}
+	return f
+}
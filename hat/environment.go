@@ -0,0 +1,111 @@
+package hat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The Sense HAT environmental sensors
+const (
+	SensorTemperature = "temperature"
+	SensorHumidity    = "humidity"
+	SensorPressure    = "pressure"
+)
+
+const (
+	humiditySensorName = "hts221"
+	pressureSensorName = "lps25h"
+)
+
+// environmentChannel is an IIO channel of an environmental sensor. IIO reports the temperature in milli degrees
+// Celsius, the humidity in milli percent and the pressure in kPa; factor converts them to degrees Celsius, percent
+// and hPa.
+type environmentChannel struct {
+	device  string
+	channel string
+	factor  float64
+}
+
+// environmentChannels are the channels of each sensor, by preference; the temperature is measured by both devices
+var environmentChannels = map[string][]environmentChannel{
+	SensorTemperature: {
+		{device: humiditySensorName, channel: "temp", factor: 0.001},
+		{device: pressureSensorName, channel: "temp", factor: 0.001},
+	},
+	SensorHumidity: {
+		{device: humiditySensorName, channel: "humidityrelative", factor: 0.001},
+	},
+	SensorPressure: {
+		{device: pressureSensorName, channel: "pressure", factor: 10},
+	},
+}
+
+// SensorNames returns the names of the environmental sensors
+func SensorNames() []string {
+	return []string{SensorTemperature, SensorHumidity, SensorPressure}
+}
+
+// EnvironmentSensor reads a Sense HAT environmental sensor from the Linux Industrial I/O sysfs interface
+type EnvironmentSensor struct {
+	dir     string
+	channel string
+	factor  float64
+}
+
+// OpenEnvironmentSensor finds the device of the sensor; one of SensorNames
+func OpenEnvironmentSensor(sensor string) (*EnvironmentSensor, error) {
+	channels, ok := environmentChannels[sensor]
+	if !ok {
+		return nil, fmt.Errorf(`unknown sensor "%s"; should be one of %s`, sensor, strings.Join(SensorNames(), ", "))
+	}
+
+	var err error
+	for _, ch := range channels {
+		var dir string
+		dir, err = findIIODevice(ch.device)
+		if err == nil {
+			return &EnvironmentSensor{dir: dir, channel: ch.channel, factor: ch.factor}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("can't find the %s sensor; %w", sensor, err)
+}
+
+// Read returns the processed value of the channel, if the device provides it, or (raw + offset) * scale
+func (s *EnvironmentSensor) Read() (float64, error) {
+	prefix := "in_" + s.channel + "_"
+	if _, err := os.Stat(filepath.Join(s.dir, prefix+"input")); err == nil {
+		value, err := readIIOValue(s.dir, prefix+"input")
+		if err != nil {
+			return 0, err
+		}
+		return value * s.factor, nil
+	}
+
+	raw, err := readIIOValue(s.dir, prefix+"raw")
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := s.readOptional(prefix+"offset", 0)
+	if err != nil {
+		return 0, err
+	}
+
+	scale, err := s.readOptional(prefix+"scale", 1)
+	if err != nil {
+		return 0, err
+	}
+
+	return (raw + offset) * scale * s.factor, nil
+}
+
+// readOptional reads an attribute that the device may not have
+func (s *EnvironmentSensor) readOptional(attr string, defaultValue float64) (float64, error) {
+	if _, err := os.Stat(filepath.Join(s.dir, attr)); os.IsNotExist(err) {
+		return defaultValue, nil
+	}
+	return readIIOValue(s.dir, attr)
+}
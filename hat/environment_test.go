@@ -0,0 +1,50 @@
+package hat
+
+import (
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the environmental sensors", func() {
+	origFunc := getIIODevicesPath
+
+	BeforeEach(func() {
+		getIIODevicesPath = func() string {
+			return path.Join(getTestFileLocation(), "iio")
+		}
+	})
+
+	AfterEach(func() {
+		getIIODevicesPath = origFunc
+	})
+
+	DescribeTable("should read the sensor", func(sensor, device string, expected float64) {
+		s, err := OpenEnvironmentSensor(sensor)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(s.dir).Should(HaveSuffix(device))
+
+		value, err := s.Read()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(value).Should(BeNumerically("~", expected, 0.001))
+	},
+		Entry("temperature, from the raw value, in Celsius", SensorTemperature, "iio:device2", 25.0),
+		Entry("humidity, from the raw value, in percent", SensorHumidity, "iio:device2", 45.0),
+		Entry("pressure, from the processed value, in hPa", SensorPressure, "iio:device3", 1013.0),
+	)
+
+	It("should reject an unknown sensor", func() {
+		_, err := OpenEnvironmentSensor("light")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should return error if the sensor is not found", func() {
+		getIIODevicesPath = func() string {
+			return path.Join(getTestFileLocation(), "graphics")
+		}
+
+		_, err := OpenEnvironmentSensor(SensorHumidity)
+		Expect(err).Should(HaveOccurred())
+	})
+})
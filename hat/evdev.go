@@ -0,0 +1,297 @@
+package hat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Linux input event types and codes; see linux/input-event-codes.h
+const (
+	evKey = 0x01
+	evAbs = 0x03
+
+	keyEnter   = 28
+	keySpace   = 57
+	keyKPEnter = 96
+	keyUp      = 103
+	keyLeft    = 105
+	keyRight   = 106
+	keyDown    = 108
+
+	btnSouth     = 0x130
+	btnDpadUp    = 0x220
+	btnDpadDown  = 0x221
+	btnDpadLeft  = 0x222
+	btnDpadRight = 0x223
+
+	absHat0X = 0x10
+	absHat0Y = 0x11
+)
+
+// the joystick events of the key codes. The Sense HAT joystick and keyboards are using the arrow keys and the Enter
+// key, and gamepads are using the D-pad and the south button (e.g. "A" or "X").
+var keyCodeEvents = map[uint16]Event{
+	keyEnter:     Pressed,
+	keySpace:     Pressed,
+	keyKPEnter:   Pressed,
+	btnSouth:     Pressed,
+	keyUp:        MoveUp,
+	btnDpadUp:    MoveUp,
+	keyDown:      MoveDown,
+	btnDpadDown:  MoveDown,
+	keyLeft:      MoveLeft,
+	btnDpadLeft:  MoveLeft,
+	keyRight:     MoveRight,
+	btnDpadRight: MoveRight,
+}
+
+// KeyState is the value of a key input event
+type KeyState int32
+
+const (
+	KeyReleased KeyState = iota
+	KeyPressed
+	KeyRepeated
+)
+
+// InputEvent is a decoded linux input_event struct
+type InputEvent struct {
+	Time  time.Time
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// KeyEvent is a joystick key that was pressed, released or auto-repeated
+type KeyEvent struct {
+	Event Event
+	State KeyState
+	Time  time.Time
+}
+
+// the input_event struct starts with a timeval struct, of two longs
+var (
+	wordSize       = strconv.IntSize / 8
+	inputEventSize = 2*wordSize + 8
+)
+
+// EvdevReader reads the linux input events from a /dev/input/eventN file, or from any other reader with the same
+// binary format
+type EvdevReader struct {
+	r   io.Reader
+	buf []byte
+	// the last pressed direction of each D-pad axis, to release it when the axis is back to 0
+	hatAxes map[uint16]Event
+}
+
+func NewEvdevReader(r io.Reader) *EvdevReader {
+	return &EvdevReader{
+		r:       r,
+		buf:     make([]byte, inputEventSize),
+		hatAxes: make(map[uint16]Event),
+	}
+}
+
+// ReadInputEvent reads the next raw input event
+func (e *EvdevReader) ReadInputEvent() (InputEvent, error) {
+	if _, err := io.ReadFull(e.r, e.buf); err != nil {
+		return InputEvent{}, err
+	}
+
+	var sec, usec int64
+	if wordSize == 8 {
+		sec = int64(binary.LittleEndian.Uint64(e.buf[0:]))
+		usec = int64(binary.LittleEndian.Uint64(e.buf[8:]))
+	} else {
+		sec = int64(int32(binary.LittleEndian.Uint32(e.buf[0:])))
+		usec = int64(int32(binary.LittleEndian.Uint32(e.buf[4:])))
+	}
+
+	data := e.buf[2*wordSize:]
+	return InputEvent{
+		Time:  time.Unix(sec, usec*int64(time.Microsecond)),
+		Type:  binary.LittleEndian.Uint16(data[0:]),
+		Code:  binary.LittleEndian.Uint16(data[2:]),
+		Value: int32(binary.LittleEndian.Uint32(data[4:])),
+	}, nil
+}
+
+// ReadKeyEvent reads the input events until it finds a joystick key event. D-pads that are reported as absolute
+// axes, are translated to key press and release events.
+func (e *EvdevReader) ReadKeyEvent() (KeyEvent, error) {
+	for {
+		ie, err := e.ReadInputEvent()
+		if err != nil {
+			return KeyEvent{}, err
+		}
+
+		switch ie.Type {
+		case evKey:
+			if event, ok := keyCodeEvents[ie.Code]; ok {
+				return KeyEvent{Event: event, State: KeyState(ie.Value), Time: ie.Time}, nil
+			}
+
+		case evAbs:
+			if keyEvent, ok := e.hatAxisEvent(ie); ok {
+				return keyEvent, nil
+			}
+		}
+	}
+}
+
+func (e *EvdevReader) hatAxisEvent(ie InputEvent) (KeyEvent, bool) {
+	var event Event
+	switch {
+	case ie.Code == absHat0X && ie.Value < 0:
+		event = MoveLeft
+	case ie.Code == absHat0X && ie.Value > 0:
+		event = MoveRight
+	case ie.Code == absHat0Y && ie.Value < 0:
+		event = MoveUp
+	case ie.Code == absHat0Y && ie.Value > 0:
+		event = MoveDown
+	case ie.Code == absHat0X || ie.Code == absHat0Y:
+		pressed, ok := e.hatAxes[ie.Code]
+		if !ok {
+			return KeyEvent{}, false
+		}
+		delete(e.hatAxes, ie.Code)
+		return KeyEvent{Event: pressed, State: KeyReleased, Time: ie.Time}, true
+	default:
+		return KeyEvent{}, false
+	}
+
+	e.hatAxes[ie.Code] = event
+	return KeyEvent{Event: event, State: KeyPressed, Time: ie.Time}, true
+}
+
+// InputSelector selects the joystick input device, by its name or its handler in the devices file, or by the device
+// file path
+type InputSelector struct {
+	Name    string
+	Handler string
+	Path    string
+}
+
+const (
+	inputNamePrefix    = "name:"
+	inputHandlerPrefix = "handler:"
+	inputPathPrefix    = "path:"
+)
+
+// DefaultInputSelector selects the Sense HAT joystick
+var DefaultInputSelector = InputSelector{Name: senseHatJoystickName}
+
+// ParseInputSelector parses the "name:<device name>", "handler:<event handler>" or "path:<device file>" format. An
+// empty string is the default selector.
+func ParseInputSelector(s string) (InputSelector, error) {
+	switch {
+	case s == "":
+		return DefaultInputSelector, nil
+	case strings.HasPrefix(s, inputNamePrefix):
+		return InputSelector{Name: strings.TrimPrefix(s, inputNamePrefix)}, nil
+	case strings.HasPrefix(s, inputHandlerPrefix):
+		return InputSelector{Handler: strings.TrimPrefix(s, inputHandlerPrefix)}, nil
+	case strings.HasPrefix(s, inputPathPrefix):
+		return InputSelector{Path: strings.TrimPrefix(s, inputPathPrefix)}, nil
+	}
+
+	return InputSelector{}, fmt.Errorf(`wrong input device "%s"; should be name:<device name>, handler:<event handler> or path:<device file>`, s)
+}
+
+func (s InputSelector) String() string {
+	switch {
+	case s.Path != "":
+		return inputPathPrefix + s.Path
+	case s.Handler != "":
+		return inputHandlerPrefix + s.Handler
+	}
+	return inputNamePrefix + s.Name
+}
+
+// InputDevice is one device in the devices file
+type InputDevice struct {
+	Name     string
+	Handlers []string
+}
+
+// eventHandler returns the event handler of the device; e.g. "event2"
+func (d InputDevice) eventHandler() string {
+	for _, handler := range d.Handlers {
+		if strings.HasPrefix(handler, "event") {
+			return handler
+		}
+	}
+	return ""
+}
+
+const (
+	namePrefix     = `N: Name="`
+	handlersPrefix = "H: Handlers="
+)
+
+// readInputDevices parses the devices file. There is an empty line between devices.
+func readInputDevices() ([]InputDevice, error) {
+	devicesFile, err := os.Open(getDevicesFilePath())
+	if err != nil {
+		return nil, err
+	}
+	defer devicesFile.Close()
+
+	var devices []InputDevice
+	var device *InputDevice
+
+	scanner := bufio.NewScanner(devicesFile)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 { // empty line indicates end of device
+			device = nil
+			continue
+		}
+
+		if device == nil {
+			devices = append(devices, InputDevice{})
+			device = &devices[len(devices)-1]
+		}
+
+		if strings.HasPrefix(line, namePrefix) {
+			device.Name = strings.TrimSuffix(strings.TrimPrefix(line, namePrefix), `"`)
+		} else if strings.HasPrefix(line, handlersPrefix) {
+			device.Handlers = strings.Fields(strings.TrimPrefix(line, handlersPrefix))
+		}
+	}
+
+	return devices, scanner.Err()
+}
+
+// findInputDeviceFile returns the device event file of the selected input device
+func findInputDeviceFile(selector InputSelector) (string, error) {
+	if selector.Path != "" {
+		return selector.Path, nil
+	}
+
+	devices, err := readInputDevices()
+	if err != nil {
+		return "", err
+	}
+
+	for _, device := range devices {
+		handler := device.eventHandler()
+		if handler == "" {
+			continue
+		}
+
+		if (selector.Handler != "" && handler == selector.Handler) ||
+			(selector.Handler == "" && device.Name == selector.Name) {
+			return eventFilePrefix + handler, nil
+		}
+	}
+
+	return "", fmt.Errorf("can't find the input device %s", selector)
+}
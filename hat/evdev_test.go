@@ -0,0 +1,194 @@
+package hat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the evdev reader", func() {
+	Context("test ReadInputEvent", func() {
+		It("should decode the input event", func() {
+			buf := &bytes.Buffer{}
+			writeInputEvent(buf, 1234, 5678, evKey, keyUp, 1)
+
+			reader := NewEvdevReader(buf)
+			ie, err := reader.ReadInputEvent()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ie.Time).Should(Equal(time.Unix(1234, 5678000)))
+			Expect(ie.Type).Should(BeEquivalentTo(evKey))
+			Expect(ie.Code).Should(BeEquivalentTo(keyUp))
+			Expect(ie.Value).Should(BeEquivalentTo(1))
+
+			_, err = reader.ReadInputEvent()
+			Expect(err).Should(Equal(io.EOF))
+		})
+
+		It("should return error for partial event", func() {
+			buf := &bytes.Buffer{}
+			writeInputEvent(buf, 0, 0, evKey, keyUp, 1)
+			buf.Truncate(inputEventSize - 1)
+
+			_, err := NewEvdevReader(buf).ReadInputEvent()
+			Expect(err).Should(Equal(io.ErrUnexpectedEOF))
+		})
+	})
+
+	Context("test ReadKeyEvent", func() {
+		It("should read press, repeat and release, and skip other events", func() {
+			buf := &bytes.Buffer{}
+			writeInputEvent(buf, 1, 0, 0x04, 4, 0x90028) // EV_MSC
+			writeInputEvent(buf, 1, 0, evKey, keyEnter, 1)
+			writeInputEvent(buf, 1, 0, 0, 0, 0) // EV_SYN
+			writeInputEvent(buf, 2, 0, evKey, keyEnter, 2)
+			writeInputEvent(buf, 2, 0, evKey, 30, 1) // KEY_A
+			writeInputEvent(buf, 3, 0, evKey, keyEnter, 0)
+
+			reader := NewEvdevReader(buf)
+			Expect(reader.ReadKeyEvent()).Should(Equal(KeyEvent{Event: Pressed, State: KeyPressed, Time: time.Unix(1, 0)}))
+			Expect(reader.ReadKeyEvent()).Should(Equal(KeyEvent{Event: Pressed, State: KeyRepeated, Time: time.Unix(2, 0)}))
+			Expect(reader.ReadKeyEvent()).Should(Equal(KeyEvent{Event: Pressed, State: KeyReleased, Time: time.Unix(3, 0)}))
+
+			_, err := reader.ReadKeyEvent()
+			Expect(err).Should(Equal(io.EOF))
+		})
+
+		DescribeTable("should map the key codes", func(code uint16, expected Event) {
+			buf := &bytes.Buffer{}
+			writeInputEvent(buf, 0, 0, evKey, code, 1)
+
+			keyEvent, err := NewEvdevReader(buf).ReadKeyEvent()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keyEvent.Event).Should(Equal(expected))
+		},
+			Entry("enter", uint16(keyEnter), Pressed),
+			Entry("gamepad south button", uint16(btnSouth), Pressed),
+			Entry("up", uint16(keyUp), MoveUp),
+			Entry("down", uint16(keyDown), MoveDown),
+			Entry("left", uint16(keyLeft), MoveLeft),
+			Entry("right", uint16(keyRight), MoveRight),
+			Entry("D-pad up", uint16(btnDpadUp), MoveUp),
+			Entry("D-pad right", uint16(btnDpadRight), MoveRight),
+		)
+
+		It("should translate the D-pad axes to keys", func() {
+			buf := &bytes.Buffer{}
+			writeInputEvent(buf, 0, 0, evAbs, absHat0Y, -1)
+			writeInputEvent(buf, 0, 0, evAbs, 0, 100) // ABS_X; ignored
+			writeInputEvent(buf, 0, 0, evAbs, absHat0Y, 0)
+			writeInputEvent(buf, 0, 0, evAbs, absHat0X, 0) // nothing to release
+			writeInputEvent(buf, 0, 0, evAbs, absHat0X, 1)
+
+			reader := NewEvdevReader(buf)
+			Expect(reader.ReadKeyEvent()).Should(Equal(KeyEvent{Event: MoveUp, State: KeyPressed, Time: time.Unix(0, 0)}))
+			Expect(reader.ReadKeyEvent()).Should(Equal(KeyEvent{Event: MoveUp, State: KeyReleased, Time: time.Unix(0, 0)}))
+			Expect(reader.ReadKeyEvent()).Should(Equal(KeyEvent{Event: MoveRight, State: KeyPressed, Time: time.Unix(0, 0)}))
+		})
+
+		It("should read from a pipe", func() {
+			r, w := io.Pipe()
+			defer r.Close()
+
+			go func() {
+				defer GinkgoRecover()
+				buf := &bytes.Buffer{}
+				writeInputEvent(buf, 0, 0, evKey, keyLeft, 1)
+				// write in two parts, to check we're reading full events
+				_, err := w.Write(buf.Bytes()[:5])
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = w.Write(buf.Bytes()[5:])
+				Expect(err).ShouldNot(HaveOccurred())
+			}()
+
+			keyEvent, err := NewEvdevReader(r).ReadKeyEvent()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keyEvent.Event).Should(Equal(MoveLeft))
+		})
+
+		It("should read recorded events from a file", func() {
+			buf := &bytes.Buffer{}
+			writeInputEvent(buf, 0, 0, evKey, keyDown, 1)
+			writeInputEvent(buf, 0, 0, evKey, keyDown, 0)
+
+			fileName := path.Join(GinkgoT().TempDir(), "events")
+			Expect(os.WriteFile(fileName, buf.Bytes(), 0644)).To(Succeed())
+
+			f, err := os.Open(fileName)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer f.Close()
+
+			reader := NewEvdevReader(f)
+			Expect(reader.ReadKeyEvent()).Should(HaveField("State", KeyPressed))
+			Expect(reader.ReadKeyEvent()).Should(HaveField("State", KeyReleased))
+		})
+	})
+
+	Context("test ParseInputSelector", func() {
+		DescribeTable("should parse the selector", func(s string, expected InputSelector) {
+			selector, err := ParseInputSelector(s)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(selector).Should(Equal(expected))
+			Expect(selector.String()).ShouldNot(BeEmpty())
+		},
+			Entry("default", "", DefaultInputSelector),
+			Entry("name", "name:USB Gamepad", InputSelector{Name: "USB Gamepad"}),
+			Entry("handler", "handler:event3", InputSelector{Handler: "event3"}),
+			Entry("path", "path:/dev/input/event3", InputSelector{Path: "/dev/input/event3"}),
+		)
+
+		It("should reject wrong format", func() {
+			_, err := ParseInputSelector("event3")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("test findInputDeviceFile", func() {
+		origFunc := getDevicesFilePath
+
+		BeforeEach(func() {
+			getDevicesFilePath = func() string {
+				return path.Join(getTestFileLocation(), "validDeviceFile.txt")
+			}
+		})
+
+		AfterEach(func() {
+			getDevicesFilePath = origFunc
+		})
+
+		DescribeTable("should find the device", func(selector InputSelector, expected string) {
+			deviceFile, err := findInputDeviceFile(selector)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(deviceFile).Should(Equal(expected))
+		},
+			Entry("default", DefaultInputSelector, "/dev/input/event2"),
+			Entry("by name", InputSelector{Name: "vc4"}, "/dev/input/event0"),
+			Entry("by handler", InputSelector{Handler: "event1"}, "/dev/input/event1"),
+			Entry("by path", InputSelector{Path: "/tmp/events"}, "/tmp/events"),
+		)
+
+		DescribeTable("should return error if the device is not found", func(selector InputSelector) {
+			_, err := findInputDeviceFile(selector)
+			Expect(err).Should(HaveOccurred())
+		},
+			Entry("by name", InputSelector{Name: "USB Gamepad"}),
+			Entry("by handler", InputSelector{Handler: "event7"}),
+		)
+	})
+})
+
+func writeInputEvent(w io.Writer, sec, usec int64, tp, code uint16, value int32) {
+	if wordSize == 8 {
+		ExpectWithOffset(1, binary.Write(w, binary.LittleEndian, []int64{sec, usec})).To(Succeed())
+	} else {
+		ExpectWithOffset(1, binary.Write(w, binary.LittleEndian, []int32{int32(sec), int32(usec)})).To(Succeed())
+	}
+	ExpectWithOffset(1, binary.Write(w, binary.LittleEndian, tp)).To(Succeed())
+	ExpectWithOffset(1, binary.Write(w, binary.LittleEndian, code)).To(Succeed())
+	ExpectWithOffset(1, binary.Write(w, binary.LittleEndian, value)).To(Succeed())
+}
@@ -0,0 +1,135 @@
+package hat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+const (
+	graphicsClassPath    = "/sys/class/graphics"
+	frameBufferDevPrefix = "/dev/"
+	senseHatFrameBuffer  = "RPi-Sense FB"
+)
+
+var getGraphicsClassPath = func() string {
+	return graphicsClassPath
+}
+
+// pixelSize is the size of a single pixel in the frame buffer, in bytes
+const pixelSize = 2
+
+// frame is the content of the LED matrix, in the HAT colors
+type frame [common.WindowSize][common.WindowSize]hatColor
+
+// FrameBuffer writes frames to a frame buffer device file. The pixels are written row by row, as 16-bit little
+// endian RGB565 values.
+type FrameBuffer struct {
+	path string
+}
+
+func NewFrameBuffer(path string) *FrameBuffer {
+	return &FrameBuffer{path: path}
+}
+
+// OpenSenseHatFrameBuffer finds the Sense HAT LED matrix frame buffer device
+func OpenSenseHatFrameBuffer() (*FrameBuffer, error) {
+	path, err := findFrameBufferDevice()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFrameBuffer(path), nil
+}
+
+// Draw writes the whole frame. The device file must exist; a wrong path is an error, and not a new regular file.
+func (fb *FrameBuffer) Draw(f *frame) error {
+	file, err := os.OpenFile(fb.path, os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err = binary.Write(file, binary.LittleEndian, f); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// DrawChanged writes only the pixels of f that are different from prev, the frame that was drawn before. Each run of
+// changed pixels is written at its offset. Without prev, the whole frame is drawn.
+func (fb *FrameBuffer) DrawChanged(f, prev *frame) error {
+	if prev == nil {
+		return fb.Draw(f)
+	}
+
+	if *f == *prev {
+		return nil
+	}
+
+	file, err := os.OpenFile(fb.path, os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	// the frame is written row by row, so the pixels are handled as one sequence
+	pixel := func(fr *frame, i int) hatColor {
+		return fr[i/common.WindowSize][i%common.WindowSize]
+	}
+	changed := func(i int) bool {
+		return pixel(f, i) != pixel(prev, i)
+	}
+
+	const numPixels = common.WindowSize * common.WindowSize
+	buf := make([]byte, 0, numPixels*pixelSize)
+	for start := 0; start < numPixels; start++ {
+		if !changed(start) {
+			continue
+		}
+
+		end := start
+		buf = buf[:0]
+		for ; end < numPixels && changed(end); end++ {
+			buf = binary.LittleEndian.AppendUint16(buf, uint16(pixel(f, end)))
+		}
+
+		if _, err = file.WriteAt(buf, int64(start*pixelSize)); err != nil {
+			_ = file.Close()
+			return err
+		}
+		start = end
+	}
+
+	return file.Close()
+}
+
+func (fb *FrameBuffer) Clear() error {
+	return fb.Draw(&frame{})
+}
+
+// findFrameBufferDevice returns the device file of the Sense HAT LED matrix frame buffer, by looking for its name in
+// the graphics class
+func findFrameBufferDevice() (string, error) {
+	matches, err := filepath.Glob(filepath.Join(getGraphicsClassPath(), "fb*"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, dir := range matches {
+		name, err := os.ReadFile(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(name)) == senseHatFrameBuffer {
+			return frameBufferDevPrefix + filepath.Base(dir), nil
+		}
+	}
+
+	return "", fmt.Errorf("can't find the Sense HAT frame buffer")
+}
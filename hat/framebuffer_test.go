@@ -0,0 +1,146 @@
+package hat
+
+import (
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the frame buffer", func() {
+	Context("test findFrameBufferDevice", func() {
+		origFunc := getGraphicsClassPath
+
+		AfterEach(func() {
+			getGraphicsClassPath = origFunc
+		})
+
+		It("should find the Sense HAT frame buffer", func() {
+			getGraphicsClassPath = func() string {
+				return path.Join(getTestFileLocation(), "graphics")
+			}
+
+			dev, err := findFrameBufferDevice()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(dev).Should(Equal("/dev/fb1"))
+
+			fb, err := OpenSenseHatFrameBuffer()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fb.path).Should(Equal("/dev/fb1"))
+		})
+
+		It("should return error if there is no Sense HAT frame buffer", func() {
+			getGraphicsClassPath = func() string {
+				return path.Join(getTestFileLocation(), "graphics", "fb0")
+			}
+
+			_, err := findFrameBufferDevice()
+			Expect(err).Should(HaveOccurred())
+
+			_, err = OpenSenseHatFrameBuffer()
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("test FrameBuffer", func() {
+		var fileName string
+
+		BeforeEach(func() {
+			fileName = path.Join(GinkgoT().TempDir(), "fb")
+			Expect(os.WriteFile(fileName, nil, 0644)).To(Succeed())
+		})
+
+		It("should write the frame as little endian RGB565", func() {
+			f := &frame{}
+			f[0][0] = 0xF800
+			f[0][1] = 0x07E0
+			f[7][7] = 0x001F
+
+			Expect(NewFrameBuffer(fileName).Draw(f)).To(Succeed())
+
+			data, err := os.ReadFile(fileName)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data).Should(HaveLen(128))
+			Expect(data[0:4]).Should(Equal([]byte{0x00, 0xF8, 0xE0, 0x07}))
+			Expect(data[4:126]).Should(Equal(make([]byte, 122)))
+			Expect(data[126:]).Should(Equal([]byte{0x1F, 0x00}))
+		})
+
+		It("should clear the frame buffer", func() {
+			Expect(os.WriteFile(fileName, []byte{1, 2, 3}, 0644)).To(Succeed())
+
+			Expect(NewFrameBuffer(fileName).Clear()).To(Succeed())
+
+			data, err := os.ReadFile(fileName)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data).Should(Equal(make([]byte, 128)))
+		})
+
+		It("should return error if the frame buffer can't be opened", func() {
+			Expect(NewFrameBuffer(path.Join(fileName, "notExists")).Clear()).ShouldNot(Succeed())
+		})
+
+		It("should not create a missing frame buffer", func() {
+			Expect(os.Remove(fileName)).To(Succeed())
+			Expect(NewFrameBuffer(fileName).Draw(&frame{})).ShouldNot(Succeed())
+			Expect(fileName).ShouldNot(BeAnExistingFile())
+		})
+
+		It("should write only the changed pixels", func() {
+			fb := NewFrameBuffer(fileName)
+			prev := &frame{}
+			Expect(fb.Draw(prev)).To(Succeed())
+
+			By("marking an unchanged pixel in the file, to see it's not written")
+			data, err := os.ReadFile(fileName)
+			Expect(err).ShouldNot(HaveOccurred())
+			data[20] = 0xAA
+			Expect(os.WriteFile(fileName, data, 0644)).To(Succeed())
+
+			f := &frame{}
+			f[0][1] = 0xF800
+			f[0][2] = 0x07E0
+			f[7][7] = 0x001F
+			Expect(fb.DrawChanged(f, prev)).To(Succeed())
+
+			data, err = os.ReadFile(fileName)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data).Should(HaveLen(128))
+			Expect(data[0:6]).Should(Equal([]byte{0x00, 0x00, 0x00, 0xF8, 0xE0, 0x07}))
+			Expect(data[20]).Should(BeEquivalentTo(0xAA))
+			Expect(data[126:]).Should(Equal([]byte{0x1F, 0x00}))
+
+			By("not writing an identical frame")
+			Expect(os.Remove(fileName)).To(Succeed())
+			Expect(fb.DrawChanged(f, f)).To(Succeed())
+			Expect(fileName).ShouldNot(BeAnExistingFile())
+		})
+
+		It("should draw the display message with the cursor", func() {
+			h := NewHat(make(chan Event), make(chan DisplayMessage), Options{Input: DefaultInputSelector})
+			h.fb = NewFrameBuffer(fileName)
+
+			msg := NewDisplayMessage(newTestScreen(0), 1, 0)
+			msg.Screen[0][0] = 0xFFFFFF
+			h.drawScreen(msg)
+
+			data, err := os.ReadFile(fileName)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data).Should(HaveLen(128))
+			Expect(data[0:6]).Should(Equal([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00}))
+
+			By("drawing only the changes of the next message")
+			data[20] = 0xAA
+			Expect(os.WriteFile(fileName, data, 0644)).To(Succeed())
+
+			msg = NewDisplayMessage(newTestScreen(0), 2, 0)
+			h.drawScreen(msg)
+
+			data, err = os.ReadFile(fileName)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data[0:6]).Should(Equal([]byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF}))
+			Expect(data[20]).Should(BeEquivalentTo(0xAA))
+		})
+	})
+})
@@ -0,0 +1,222 @@
+package hat
+
+import (
+	"time"
+)
+
+// Clock returns the current time. It's replaced in tests, to make the gestures timing deterministic.
+type Clock func() time.Time
+
+// GestureTiming is the timing of the joystick gestures
+type GestureTiming struct {
+	// LongPress is how long the button is held, to be a long press
+	LongPress time.Duration
+	// DoublePress is the maximum time between two presses of a double press
+	DoublePress time.Duration
+	// RepeatDelay is how long a direction is held before it starts repeating
+	RepeatDelay time.Duration
+	// RepeatInterval is the first interval between the repeats. Each repeat is faster, until MinRepeatInterval.
+	RepeatInterval    time.Duration
+	MinRepeatInterval time.Duration
+}
+
+var DefaultGestureTiming = GestureTiming{
+	LongPress:         600 * time.Millisecond,
+	DoublePress:       250 * time.Millisecond,
+	RepeatDelay:       400 * time.Millisecond,
+	RepeatInterval:    200 * time.Millisecond,
+	MinRepeatInterval: 40 * time.Millisecond,
+}
+
+// the double press event of each direction
+var doubleMoveEvents = map[Event]Event{
+	MoveUp:    DoubleMoveUp,
+	MoveLeft:  DoubleMoveLeft,
+	MoveDown:  DoubleMoveDown,
+	MoveRight: DoubleMoveRight,
+}
+
+// GestureRecognizer translates the joystick key events to gesture events.
+//
+// The button sends Pressed when it's released, unless it was held long enough to send LongPressed, or pressed again
+// soon enough to send DoublePressed. The directions send their move event when pressed, and repeat it, faster and
+// faster, while they are held. Pressing a direction twice sends its double move event.
+//
+// The recognizer does not use timers; Tick should be called at NextDeadline to send the time based events.
+type GestureRecognizer struct {
+	timing GestureTiming
+	now    Clock
+
+	buttonDown     bool
+	buttonDownAt   time.Time
+	buttonConsumed bool // the current press already sent its event
+	pendingPress   bool // the button was released, and may be pressed again
+	releasedAt     time.Time
+
+	held           Event
+	isHeld         bool
+	nextRepeat     time.Time
+	repeatInterval time.Duration
+	lastMove       map[Event]time.Time
+}
+
+func NewGestureRecognizer(timing GestureTiming, clock Clock) *GestureRecognizer {
+	return &GestureRecognizer{
+		timing:   timing,
+		now:      clock,
+		lastMove: make(map[Event]time.Time),
+	}
+}
+
+// Feed handles a key event, and returns the gesture events it caused. The kernel auto-repeat is ignored; the
+// recognizer repeats the held directions by itself.
+func (g *GestureRecognizer) Feed(keyEvent KeyEvent) []Event {
+	if keyEvent.State == KeyRepeated {
+		return nil
+	}
+
+	now := g.now()
+	events := g.Tick()
+
+	if keyEvent.Event == Pressed {
+		if keyEvent.State == KeyPressed {
+			return append(events, g.pressButton(now)...)
+		}
+		g.releaseButton(now)
+		return events
+	}
+
+	if keyEvent.State == KeyPressed {
+		return append(events, g.pressDirection(keyEvent.Event, now))
+	}
+
+	if g.isHeld && g.held == keyEvent.Event {
+		g.isHeld = false
+	}
+	return events
+}
+
+func (g *GestureRecognizer) pressButton(now time.Time) []Event {
+	g.buttonDown = true
+	g.buttonDownAt = now
+	g.buttonConsumed = false
+
+	if g.pendingPress {
+		g.pendingPress = false
+		g.buttonConsumed = true
+		return []Event{DoublePressed}
+	}
+
+	return nil
+}
+
+func (g *GestureRecognizer) releaseButton(now time.Time) {
+	if !g.buttonDown {
+		return
+	}
+
+	g.buttonDown = false
+	if !g.buttonConsumed {
+		g.pendingPress = true
+		g.releasedAt = now
+	}
+}
+
+func (g *GestureRecognizer) pressDirection(direction Event, now time.Time) Event {
+	if last, ok := g.lastMove[direction]; ok && now.Sub(last) <= g.timing.DoublePress {
+		delete(g.lastMove, direction)
+		g.isHeld = false
+		return doubleMoveEvents[direction]
+	}
+
+	g.lastMove[direction] = now
+	g.held = direction
+	g.isHeld = true
+	g.nextRepeat = now.Add(g.timing.RepeatDelay)
+	g.repeatInterval = g.timing.RepeatInterval
+
+	return direction
+}
+
+// Tick returns the time based events that are due
+func (g *GestureRecognizer) Tick() []Event {
+	now := g.now()
+	var events []Event
+
+	if g.buttonDown && !g.buttonConsumed && now.Sub(g.buttonDownAt) >= g.timing.LongPress {
+		g.buttonConsumed = true
+		events = append(events, LongPressed)
+	}
+
+	if g.pendingPress && now.Sub(g.releasedAt) > g.timing.DoublePress {
+		g.pendingPress = false
+		events = append(events, Pressed)
+	}
+
+	for g.isHeld && !now.Before(g.nextRepeat) {
+		events = append(events, g.held)
+		g.nextRepeat = g.nextRepeat.Add(g.repeatInterval)
+		g.repeatInterval = g.repeatInterval * 3 / 4
+		if g.repeatInterval < g.timing.MinRepeatInterval {
+			g.repeatInterval = g.timing.MinRepeatInterval
+		}
+	}
+
+	return events
+}
+
+// NextDeadline returns when Tick should be called next, if there is a pending time based event
+func (g *GestureRecognizer) NextDeadline() (time.Time, bool) {
+	var deadline time.Time
+	found := false
+
+	setDeadline := func(t time.Time) {
+		if !found || t.Before(deadline) {
+			deadline = t
+			found = true
+		}
+	}
+
+	if g.buttonDown && !g.buttonConsumed {
+		setDeadline(g.buttonDownAt.Add(g.timing.LongPress))
+	}
+
+	if g.pendingPress {
+		// the double press window is inclusive, so the press is sent right after it
+		setDeadline(g.releasedAt.Add(g.timing.DoublePress + time.Nanosecond))
+	}
+
+	if g.isHeld {
+		setDeadline(g.nextRepeat)
+	}
+
+	return deadline, found
+}
+
+// gestureTimer wakes the HAT goroutine at the next gesture deadline. When there is no deadline, its channel is nil.
+type gestureTimer struct {
+	timer *time.Timer
+	c     <-chan time.Time
+}
+
+func newGestureTimer() *gestureTimer {
+	return &gestureTimer{}
+}
+
+func (t *gestureTimer) reset(deadline time.Time, ok bool) {
+	t.stop()
+	if !ok {
+		return
+	}
+
+	t.timer = time.NewTimer(time.Until(deadline))
+	t.c = t.timer.C
+}
+
+func (t *gestureTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.c = nil
+}
@@ -0,0 +1,221 @@
+package hat
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+var _ = Describe("test the gesture recognizer", func() {
+	var (
+		clock *fakeClock
+		g     *GestureRecognizer
+	)
+
+	press := func(e Event) []Event {
+		return g.Feed(KeyEvent{Event: e, State: KeyPressed})
+	}
+
+	release := func(e Event) []Event {
+		return g.Feed(KeyEvent{Event: e, State: KeyReleased})
+	}
+
+	BeforeEach(func() {
+		clock = &fakeClock{now: time.Unix(1000, 0)}
+		g = NewGestureRecognizer(DefaultGestureTiming, clock.Now)
+	})
+
+	Context("test the button", func() {
+		It("should send press after the double press time", func() {
+			Expect(press(Pressed)).Should(BeEmpty())
+			clock.Advance(100 * time.Millisecond)
+			Expect(release(Pressed)).Should(BeEmpty())
+
+			deadline, ok := g.NextDeadline()
+			Expect(ok).Should(BeTrue())
+			Expect(deadline).Should(Equal(clock.now.Add(DefaultGestureTiming.DoublePress + time.Nanosecond)))
+
+			clock.Advance(DefaultGestureTiming.DoublePress)
+			Expect(g.Tick()).Should(BeEmpty())
+
+			clock.Advance(time.Millisecond)
+			Expect(g.Tick()).Should(Equal([]Event{Pressed}))
+
+			_, ok = g.NextDeadline()
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("should send the pending press before the next event", func() {
+			press(Pressed)
+			release(Pressed)
+			clock.Advance(time.Second)
+
+			Expect(press(MoveUp)).Should(Equal([]Event{Pressed, MoveUp}))
+		})
+
+		It("should send double press", func() {
+			press(Pressed)
+			clock.Advance(50 * time.Millisecond)
+			release(Pressed)
+			clock.Advance(DefaultGestureTiming.DoublePress)
+			Expect(press(Pressed)).Should(Equal([]Event{DoublePressed}))
+
+			clock.Advance(50 * time.Millisecond)
+			Expect(release(Pressed)).Should(BeEmpty())
+
+			_, ok := g.NextDeadline()
+			Expect(ok).Should(BeFalse())
+
+			clock.Advance(time.Second)
+			Expect(g.Tick()).Should(BeEmpty())
+		})
+
+		It("should send long press while the button is held", func() {
+			press(Pressed)
+
+			deadline, ok := g.NextDeadline()
+			Expect(ok).Should(BeTrue())
+			Expect(deadline).Should(Equal(clock.now.Add(DefaultGestureTiming.LongPress)))
+
+			clock.Advance(DefaultGestureTiming.LongPress - time.Millisecond)
+			Expect(g.Tick()).Should(BeEmpty())
+
+			clock.Advance(time.Millisecond)
+			Expect(g.Tick()).Should(Equal([]Event{LongPressed}))
+
+			clock.Advance(time.Second)
+			Expect(g.Tick()).Should(BeEmpty())
+			Expect(release(Pressed)).Should(BeEmpty())
+
+			clock.Advance(time.Second)
+			Expect(g.Tick()).Should(BeEmpty())
+		})
+
+		It("should ignore the kernel auto-repeat", func() {
+			press(Pressed)
+			Expect(g.Feed(KeyEvent{Event: Pressed, State: KeyRepeated})).Should(BeEmpty())
+		})
+
+		It("should ignore release without press", func() {
+			Expect(release(Pressed)).Should(BeEmpty())
+			_, ok := g.NextDeadline()
+			Expect(ok).Should(BeFalse())
+		})
+	})
+
+	Context("test the directions", func() {
+		It("should move immediately", func() {
+			Expect(press(MoveLeft)).Should(Equal([]Event{MoveLeft}))
+			Expect(release(MoveLeft)).Should(BeEmpty())
+
+			_, ok := g.NextDeadline()
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("should repeat faster while held", func() {
+			Expect(press(MoveDown)).Should(Equal([]Event{MoveDown}))
+
+			clock.Advance(DefaultGestureTiming.RepeatDelay)
+			Expect(g.Tick()).Should(Equal([]Event{MoveDown}))
+
+			var intervals []time.Duration
+			for i := 0; i < 8; i++ {
+				deadline, ok := g.NextDeadline()
+				Expect(ok).Should(BeTrue())
+				intervals = append(intervals, deadline.Sub(clock.now))
+
+				clock.now = deadline
+				Expect(g.Tick()).Should(Equal([]Event{MoveDown}))
+			}
+
+			Expect(intervals).Should(Equal([]time.Duration{
+				200 * time.Millisecond,
+				150 * time.Millisecond,
+				112500 * time.Microsecond,
+				84375 * time.Microsecond,
+				63281250 * time.Nanosecond,
+				47460937 * time.Nanosecond,
+				40 * time.Millisecond,
+				40 * time.Millisecond,
+			}))
+
+			Expect(release(MoveDown)).Should(BeEmpty())
+			_, ok := g.NextDeadline()
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("should send all the missed repeats", func() {
+			press(MoveRight)
+			clock.Advance(DefaultGestureTiming.RepeatDelay + DefaultGestureTiming.RepeatInterval)
+			Expect(g.Tick()).Should(Equal([]Event{MoveRight, MoveRight}))
+		})
+
+		It("should send double move", func() {
+			press(MoveUp)
+			clock.Advance(50 * time.Millisecond)
+			release(MoveUp)
+			clock.Advance(50 * time.Millisecond)
+
+			Expect(press(MoveUp)).Should(Equal([]Event{DoubleMoveUp}))
+			_, ok := g.NextDeadline()
+			Expect(ok).Should(BeFalse())
+
+			By("a third press is a new move")
+			release(MoveUp)
+			Expect(press(MoveUp)).Should(Equal([]Event{MoveUp}))
+		})
+
+		It("should not send double move for different directions", func() {
+			press(MoveUp)
+			release(MoveUp)
+			Expect(press(MoveLeft)).Should(Equal([]Event{MoveLeft}))
+		})
+
+		It("should not send double move if pressed too late", func() {
+			press(MoveUp)
+			release(MoveUp)
+			clock.Advance(DefaultGestureTiming.DoublePress + time.Millisecond)
+			Expect(press(MoveUp)).Should(Equal([]Event{MoveUp}))
+		})
+	})
+
+	Context("test the gesture timer", func() {
+		It("should fire at the deadline", func() {
+			t := newGestureTimer()
+			Expect(t.c).Should(BeNil())
+
+			t.reset(time.Now().Add(time.Millisecond), true)
+			Eventually(t.c).Should(Receive())
+
+			t.reset(time.Time{}, false)
+			Expect(t.c).Should(BeNil())
+		})
+	})
+})
+
+var _ = Describe("test ParseEvent", func() {
+	It("should parse the event names", func() {
+		for event, name := range eventNames {
+			Expect(ParseEvent(name)).Should(Equal(event))
+			Expect(event.String()).Should(Equal(name))
+		}
+	})
+
+	It("should reject unknown event", func() {
+		_, err := ParseEvent("TriplePressed")
+		Expect(err).Should(HaveOccurred())
+	})
+})
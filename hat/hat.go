@@ -1,27 +1,25 @@
 package hat
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"strings"
-
-	"github.com/nathany/bobblehat/sense/screen"
-	"github.com/nathany/bobblehat/sense/screen/color"
-	"github.com/nathany/bobblehat/sense/stick"
+	"time"
 
 	"github.com/nunnatsa/piHatDraw/common"
 )
 
 type Interface interface {
-	Start()
+	Start() error
 	Stop()
 }
 
 // The format of the HAT color is 16-bit: 5 MS bits are the red color, the middle 6 bits are
 // green and the 5 LB bits are blue
 // rrrrrggggggbbbbb
+type hatColor uint16
+
 const (
 	rmask common.Color = 0b111110000000000000000000
 	gmask common.Color = 0b000000001111110000000000
@@ -30,10 +28,10 @@ const (
 
 // to convert 24-bit color to 16-bit color, we are taking only the 5 (for red and
 // blue) or 6 (for green) MS bits
-func toHatColor(c common.Color) color.Color {
-	r := color.Color((c & rmask) >> 8)
-	g := color.Color((c & gmask) >> 5)
-	b := color.Color((c & bmask) >> 3)
+func toHatColor(c common.Color) hatColor {
+	r := hatColor((c & rmask) >> 8)
+	g := hatColor((c & gmask) >> 5)
+	b := hatColor((c & bmask) >> 3)
 
 	return r | g | b
 }
@@ -47,13 +45,69 @@ const (
 	MoveLeft
 	MoveDown
 	MoveRight
+
+	// gesture events
+	LongPressed
+	DoublePressed
+	DoubleMoveUp
+	DoubleMoveLeft
+	DoubleMoveDown
+	DoubleMoveRight
+	Shaken
 )
 
+var eventNames = map[Event]string{
+	Pressed:         "Pressed",
+	MoveUp:          "MoveUp",
+	MoveLeft:        "MoveLeft",
+	MoveDown:        "MoveDown",
+	MoveRight:       "MoveRight",
+	LongPressed:     "LongPressed",
+	DoublePressed:   "DoublePressed",
+	DoubleMoveUp:    "DoubleMoveUp",
+	DoubleMoveLeft:  "DoubleMoveLeft",
+	DoubleMoveDown:  "DoubleMoveDown",
+	DoubleMoveRight: "DoubleMoveRight",
+	Shaken:          "Shaken",
+}
+
+func (e Event) String() string {
+	if name, ok := eventNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("Event(%d)", uint8(e))
+}
+
+// IsGesture returns false for the basic joystick events; the press and the moves
+func (e Event) IsGesture() bool {
+	return e >= LongPressed
+}
+
+// ParseEvent returns the event with the name
+func ParseEvent(name string) (Event, error) {
+	for event, eventName := range eventNames {
+		if eventName == name {
+			return event, nil
+		}
+	}
+	return 0, fmt.Errorf(`unknown joystick event "%s"`, name)
+}
+
 // HAT display events
 type DisplayMessage struct {
 	Screen  [][]common.Color
 	CursorX uint8
 	CursorY uint8
+	// WindowX and WindowY are the position of the display on the canvas
+	WindowX uint8
+	WindowY uint8
+	// Settings is the color management of the display. When nil, the colors are displayed as is.
+	Settings *DisplaySettings
+	// Zoom is the size of the LED block that shows a single canvas pixel. The cursor is the block that starts at
+	// CursorX, CursorY. Zero is the same as 1.
+	Zoom uint8
+	// NoCursor hides the cursor; e.g. while a text is scrolling
+	NoCursor bool
 }
 
 func NewDisplayMessage(mat [][]common.Color, x, y uint8) DisplayMessage {
@@ -61,121 +115,273 @@ func NewDisplayMessage(mat [][]common.Color, x, y uint8) DisplayMessage {
 		Screen:  mat,
 		CursorX: x,
 		CursorY: y,
+		Zoom:    1,
 	}
 }
 
+// Equal returns true if both messages are displayed the same
+func (m DisplayMessage) Equal(other DisplayMessage) bool {
+	if m.CursorX != other.CursorX || m.CursorY != other.CursorY || m.WindowX != other.WindowX ||
+		m.WindowY != other.WindowY || m.cursorSize() != other.cursorSize() || m.NoCursor != other.NoCursor {
+		return false
+	}
+
+	if (m.Settings == nil) != (other.Settings == nil) || (m.Settings != nil && *m.Settings != *other.Settings) {
+		return false
+	}
+
+	if len(m.Screen) != len(other.Screen) {
+		return false
+	}
+
+	for y, line := range m.Screen {
+		if len(line) != len(other.Screen[y]) {
+			return false
+		}
+		for x, c := range line {
+			if c != other.Screen[y][x] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (m DisplayMessage) cursorSize() int {
+	if m.Zoom == 0 {
+		return 1
+	}
+	return int(m.Zoom)
+}
+
+// isCursor returns true if the display pixel at x, y is part of the cursor
+func (m DisplayMessage) isCursor(x, y int) bool {
+	size := m.cursorSize()
+	return x >= int(m.CursorX) && x < int(m.CursorX)+size && y >= int(m.CursorY) && y < int(m.CursorY)+size
+}
+
+// convert converts the color of the display pixel at x, y, to the HAT color. The dithering position is in LEDs from
+// the canvas origin, so the pattern moves with the image when the window is moved.
+func (m DisplayMessage) convert(c common.Color, x, y int) hatColor {
+	zoom := m.cursorSize()
+	return m.Settings.convert(c, int(m.WindowX)*zoom+x, int(m.WindowY)*zoom+y)
+}
+
 type Hat struct {
-	events chan<- Event
-	screen <-chan DisplayMessage
-	done   chan struct{}
-	input  *stick.Device
+	events      chan<- Event
+	screen      <-chan DisplayMessage
+	done        chan struct{}
+	selector    InputSelector
+	orientation Orientation
+	input       io.ReadCloser
+	fb          *FrameBuffer
+	keys        chan KeyEvent
+	blink       *blinker
+	lastScreen  DisplayMessage
+	// drawn is the frame in the frame buffer, or nil if it's unknown
+	drawn  *frame
+	imu    bool
+	accel  *Accelerometer
+	motion chan Event
+	*drawStatus
 }
 
-func NewHat(joystickEvents chan<- Event, screenEvents <-chan DisplayMessage) *Hat {
+func NewHat(joystickEvents chan<- Event, screenEvents <-chan DisplayMessage, opts Options) *Hat {
 	return &Hat{
-		events: joystickEvents,
-		screen: screenEvents,
-		done:   make(chan struct{}),
+		events:      joystickEvents,
+		screen:      screenEvents,
+		done:        make(chan struct{}),
+		selector:    opts.Input,
+		orientation: opts.Orientation,
+		keys:        make(chan KeyEvent, 4),
+		blink:       newBlinker(),
+		imu:         opts.IMU,
+		motion:      make(chan Event, 4),
+		drawStatus:  &drawStatus{},
 	}
 }
 
-func (h *Hat) Start() {
-	h.init()
+func (h *Hat) Start() error {
+	if err := h.init(); err != nil {
+		return err
+	}
+
+	go h.readKeys()
+	if h.accel != nil {
+		go h.readMotion()
+	}
 	go h.do()
+
+	return nil
 }
 
 func (h *Hat) Stop() {
 	close(h.done)
 }
 
-func (h *Hat) init() {
-	joystickFile, err := findJoystickDeviceFile()
+func (h *Hat) init() error {
+	joystickFile, err := findInputDeviceFile(h.selector)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Can't find the device event file for the Sense Hat joystick")
-		os.Exit(1)
+		return fmt.Errorf("can't find the device event file for the joystick; %w", err)
 	}
-	h.input, err = stick.Open(joystickFile)
+	h.input, err = os.Open(joystickFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Can't open '%s'; %v\n", joystickFile, err)
-		os.Exit(1)
+		return fmt.Errorf("can't open '%s'; %w", joystickFile, err)
 	}
 
-	if err = screen.Clear(); err != nil {
-		fmt.Fprintln(os.Stderr, "Can't clear the HAT display", err)
-		os.Exit(1)
+	h.fb, err = OpenSenseHatFrameBuffer()
+	if err != nil {
+		_ = h.input.Close()
+		return fmt.Errorf("can't find the HAT display; %w", err)
+	}
+
+	if err = h.fb.Clear(); err != nil {
+		_ = h.input.Close()
+		return fmt.Errorf("can't clear the HAT display; %w", err)
+	}
+
+	if h.imu {
+		h.accel, err = OpenAccelerometer()
+		if err != nil {
+			log.Printf("Can't find the accelerometer; running without the motion gestures; %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *Hat) readKeys() {
+	_ = readKeyEvents(h.input, h.keys, h.done)
+}
+
+// readKeyEvents reads the key events of the input device, and sends them to keys, until done is closed. It returns the
+// read error, or nil if done was closed.
+func readKeyEvents(input io.Reader, keys chan<- KeyEvent, done <-chan struct{}) error {
+	reader := NewEvdevReader(input)
+	for {
+		keyEvent, err := reader.ReadKeyEvent()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case keys <- keyEvent:
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// readMotion polls the accelerometer, and sends the motion events
+func (h *Hat) readMotion() {
+	detector := NewMotionDetector(DefaultMotionSettings)
+	ticker := time.NewTicker(imuPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.done:
+			return
+		}
+
+		sample, err := h.accel.Read()
+		if err != nil {
+			log.Println("Can't read the accelerometer; stopping the motion gestures;", err)
+			return
+		}
+
+		for _, event := range detector.Feed(sample) {
+			select {
+			case h.motion <- event:
+			case <-h.done:
+				return
+			}
+		}
 	}
 }
 
 func (h *Hat) do() {
 	defer h.gracefulShutDown()
 
+	gestures := NewGestureRecognizer(DefaultGestureTiming, time.Now)
+	timer := newGestureTimer()
+	defer timer.stop()
+	defer h.blink.stop()
+
 	for {
 		select {
-		case event := <-h.input.Events:
-			switch event.Code {
-			case stick.Enter:
-				h.events <- Pressed
-				log.Println("Joystick Event: Pressed")
-
-			case stick.Up:
-				h.events <- MoveUp
-				log.Println("Joystick Event: MoveUp")
-
-			case stick.Down:
-				h.events <- MoveDown
-				log.Println("Joystick Event: MoveDown")
-
-			case stick.Left:
-				h.events <- MoveLeft
-				log.Println("Joystick Event: MoveLeft")
-
-			case stick.Right:
-				h.events <- MoveRight
-				log.Println("Joystick Event: MoveRight")
-			}
+		case keyEvent := <-h.keys:
+			keyEvent.Event = h.orientation.MapEvent(keyEvent.Event)
+			h.sendEvents(gestures.Feed(keyEvent))
+
+		case <-timer.c:
+			h.sendEvents(gestures.Tick())
+
+		case event := <-h.motion:
+			h.sendEvents([]Event{h.orientation.MapEvent(event)})
 
 		case screenChange := <-h.screen:
+			h.blink.reset(screenChange.blinking())
 			h.drawScreen(screenChange)
 
+		case <-h.blink.c:
+			h.blink.toggle()
+			h.drawScreen(h.lastScreen)
+
 		case <-h.done:
 			return
 		}
+
+		timer.reset(gestures.NextDeadline())
+	}
+}
+
+func (h *Hat) sendEvents(events []Event) {
+	for _, event := range events {
+		h.events <- event
+		log.Println("Joystick Event:", event)
 	}
 }
 
+// drawScreen draws the display message in the HAT orientation. The colors are converted at their display position, so
+// the dithering pattern does not depend on the orientation. Only the changed pixels are written to the frame buffer.
 func (h *Hat) drawScreen(screenChange DisplayMessage) {
-	fb := screen.NewFrameBuffer()
-	for y := 0; y < 8; y++ {
-		for x := 0; x < 8; x++ {
-			fb.SetPixel(x, y, toHatColor(screenChange.Screen[y][x]))
+	h.lastScreen = screenChange
+
+	f := &frame{}
+	for y := 0; y < common.WindowSize; y++ {
+		for x := 0; x < common.WindowSize; x++ {
+			c := screenChange.pixelColor(x, y, h.blink.on)
+			fx, fy := h.orientation.transform(x, y)
+			f[fy][fx] = screenChange.convert(c, x, y)
 		}
 	}
 
-	cursorOrigColor := toHatColor(screenChange.Screen[screenChange.CursorY][screenChange.CursorX])
-	cursorColor := reversColor(cursorOrigColor)
-
-	fb.SetPixel(int(screenChange.CursorX), int(screenChange.CursorY), cursorColor)
-	err := screen.Draw(fb)
+	err := h.fb.DrawChanged(f, h.drawn)
 	if err != nil {
 		log.Println("error while printing to HAT display:", err)
+		// the frame buffer content is unknown; draw the whole frame next time
+		h.drawn = nil
+	} else {
+		h.drawn = f
 	}
-}
-
-func reversColor(c color.Color) color.Color {
-	return c ^ 0b1111111111111111
+	h.set(err)
 }
 
 func (h Hat) gracefulShutDown() {
-	_ = screen.Clear()
+	_ = h.fb.Clear()
+	// stop reading the joystick
+	_ = h.input.Close()
 	// signal the controller we've done
 	close(h.events)
 }
 
 const (
-	devicesFilePath  = "/proc/bus/input/devices"
-	eventFilePrefix  = "/dev/input/"
-	handlerPrefix    = "H: Handlers=kbd "
-	expectedNameLine = `N: Name="Raspberry Pi Sense HAT Joystick"`
+	devicesFilePath      = "/proc/bus/input/devices"
+	eventFilePrefix      = "/dev/input/"
+	senseHatJoystickName = "Raspberry Pi Sense HAT Joystick"
 )
 
 var getDevicesFilePath = func() string {
@@ -183,40 +389,5 @@ var getDevicesFilePath = func() string {
 }
 
 func findJoystickDeviceFile() (string, error) {
-	devicesFile, err := os.Open(getDevicesFilePath())
-	if err != nil {
-		return "", err
-	}
-	defer devicesFile.Close()
-
-	scanner := bufio.NewScanner(devicesFile)
-	foundDevice := false
-	deviceName := ""
-
-	// scan the file to find the joystick device event name
-	// there is an empty line between devices.
-	// we're looking two line in one device, one with the device name and one with the device handler.
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) == 0 { // empty line indicates end of device
-			// start scanning new device
-			foundDevice = false
-			deviceName = ""
-		} else if line == expectedNameLine {
-			foundDevice = true
-			if len(deviceName) > 0 {
-				break // found
-			}
-		} else if strings.HasPrefix(line, handlerPrefix) {
-			deviceName = strings.Trim(strings.TrimPrefix(line, handlerPrefix), " ")
-			if foundDevice {
-				break // found
-			}
-		}
-	}
-
-	if foundDevice && len(deviceName) > 0 {
-		return eventFilePrefix + deviceName, nil // found
-	}
-	return "", fmt.Errorf("can't find the joystick device")
+	return findInputDeviceFile(DefaultInputSelector)
 }
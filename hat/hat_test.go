@@ -44,6 +44,28 @@ var _ = Describe("test the hat package", func() {
 		})
 	})
 
+	Context("test the cursor of the display message", func() {
+		It("should be a single pixel without zoom", func() {
+			msg := NewDisplayMessage(newTestScreen(0), 3, 5)
+			Expect(msg.isCursor(3, 5)).Should(BeTrue())
+			Expect(msg.isCursor(4, 5)).Should(BeFalse())
+			Expect(msg.isCursor(3, 6)).Should(BeFalse())
+
+			msg.Zoom = 0
+			Expect(msg.isCursor(3, 5)).Should(BeTrue())
+			Expect(msg.isCursor(4, 6)).Should(BeFalse())
+		})
+
+		It("should be a block with zoom", func() {
+			msg := NewDisplayMessage(newTestScreen(0), 4, 2)
+			msg.Zoom = 2
+			Expect(msg.isCursor(4, 2)).Should(BeTrue())
+			Expect(msg.isCursor(5, 3)).Should(BeTrue())
+			Expect(msg.isCursor(6, 3)).Should(BeFalse())
+			Expect(msg.isCursor(3, 2)).Should(BeFalse())
+		})
+	})
+
 	Context("test findJoystickDeviceFile", func() {
 		origFunc := getDevicesFilePath
 
@@ -0,0 +1,61 @@
+package hat
+
+import "fmt"
+
+// Headless is a HAT backend with no hardware. It discards the display messages and never sends joystick events, so
+// the application can still be used from the web.
+type Headless struct {
+	events chan<- Event
+	screen <-chan DisplayMessage
+	done   chan struct{}
+	// cause is the start error of the HAT backend that this backend replaces
+	cause error
+}
+
+func NewHeadless(joystickEvents chan<- Event, screenEvents <-chan DisplayMessage) *Headless {
+	return &Headless{
+		events: joystickEvents,
+		screen: screenEvents,
+		done:   make(chan struct{}),
+	}
+}
+
+// NewHeadlessFallback creates a headless backend instead of a HAT backend that failed to start. The fallback is
+// reported as unhealthy, with the start error.
+func NewHeadlessFallback(joystickEvents chan<- Event, screenEvents <-chan DisplayMessage, cause error) *Headless {
+	h := NewHeadless(joystickEvents, screenEvents)
+	h.cause = cause
+	return h
+}
+
+// Health returns an error if the backend is a fallback
+func (h *Headless) Health() error {
+	if h.cause != nil {
+		return fmt.Errorf("headless fallback: %v", h.cause)
+	}
+	return nil
+}
+
+func (h *Headless) Start() error {
+	go h.do()
+	return nil
+}
+
+func (h *Headless) Stop() {
+	close(h.done)
+}
+
+func (h *Headless) do() {
+	// signal the controller we've done
+	defer close(h.events)
+
+	for {
+		select {
+		case <-h.screen:
+			// nothing to display
+
+		case <-h.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package hat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const iioDevicesPath = "/sys/bus/iio/devices"
+
+var getIIODevicesPath = func() string {
+	return iioDevicesPath
+}
+
+// findIIODevice returns the sysfs directory of the Industrial I/O device with the name
+func findIIODevice(name string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(getIIODevicesPath(), "iio:device*"))
+	if err != nil {
+		return "", err
+	}
+
+	for _, dir := range matches {
+		deviceName, err := os.ReadFile(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(deviceName)) == name {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf(`can't find the IIO device "%s"`, name)
+}
+
+// readIIOValue reads a numeric attribute of an IIO device
+func readIIOValue(dir, attr string) (float64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, attr))
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("wrong value in %s; %w", attr, err)
+	}
+
+	return value, nil
+}
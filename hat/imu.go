@@ -0,0 +1,168 @@
+package hat
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	accelerometerName = "lsm9ds1_accel"
+	standardGravity   = 9.80665
+	imuPollInterval   = 50 * time.Millisecond
+)
+
+// AccelSample is an accelerometer reading. The axes are in g; X is to the right of the LED matrix, and Y is to its
+// bottom.
+type AccelSample struct {
+	X, Y, Z float64
+	Time    time.Time
+}
+
+// Accelerometer reads the Sense HAT accelerometer from the Linux Industrial I/O sysfs interface
+type Accelerometer struct {
+	dir   string
+	scale float64
+}
+
+// OpenAccelerometer finds the Sense HAT accelerometer
+func OpenAccelerometer() (*Accelerometer, error) {
+	dir, err := findIIODevice(accelerometerName)
+	if err != nil {
+		return nil, err
+	}
+
+	scale, err := readIIOValue(dir, "in_accel_scale")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Accelerometer{dir: dir, scale: scale}, nil
+}
+
+func (a *Accelerometer) Read() (AccelSample, error) {
+	var axes [3]float64
+	for i, axis := range []string{"x", "y", "z"} {
+		raw, err := readIIOValue(a.dir, "in_accel_"+axis+"_raw")
+		if err != nil {
+			return AccelSample{}, err
+		}
+		// the scale is in m/s^2
+		axes[i] = raw * a.scale / standardGravity
+	}
+
+	return AccelSample{X: axes[0], Y: axes[1], Z: axes[2], Time: time.Now()}, nil
+}
+
+// MotionSettings are the thresholds of the motion gestures
+type MotionSettings struct {
+	// TiltThreshold is how far the HAT is tilted, in g, to move the cursor
+	TiltThreshold float64
+	// TiltRepeat is the interval between the moves, while the HAT is tilted
+	TiltRepeat time.Duration
+	// ShakeThreshold is how far the acceleration is from 1g, to be a jolt
+	ShakeThreshold float64
+	// ShakeCount jolts in ShakeWindow are a shake
+	ShakeCount  int
+	ShakeWindow time.Duration
+}
+
+var DefaultMotionSettings = MotionSettings{
+	TiltThreshold:  0.35,
+	TiltRepeat:     300 * time.Millisecond,
+	ShakeThreshold: 0.8,
+	ShakeCount:     3,
+	ShakeWindow:    time.Second,
+}
+
+// MotionDetector translates the accelerometer samples to joystick events. Tilting the HAT moves the cursor towards
+// the lower side, and repeats the move while it's tilted. Shaking the HAT sends the Shaken event. The tilt is ignored
+// while the HAT is shaken.
+type MotionDetector struct {
+	settings MotionSettings
+
+	tilted    bool
+	direction Event
+	nextMove  time.Time
+
+	inJolt     bool
+	jolts      []time.Time
+	quietUntil time.Time
+}
+
+func NewMotionDetector(settings MotionSettings) *MotionDetector {
+	return &MotionDetector{settings: settings}
+}
+
+// Feed handles an accelerometer sample, and returns the events it caused
+func (d *MotionDetector) Feed(sample AccelSample) []Event {
+	magnitude := math.Sqrt(sample.X*sample.X + sample.Y*sample.Y + sample.Z*sample.Z)
+	jolt := math.Abs(magnitude-1) > d.settings.ShakeThreshold
+
+	// forget the old jolts
+	for len(d.jolts) > 0 && sample.Time.Sub(d.jolts[0]) > d.settings.ShakeWindow {
+		d.jolts = d.jolts[1:]
+	}
+
+	if jolt {
+		d.tilted = false
+		d.quietUntil = sample.Time.Add(d.settings.ShakeWindow)
+
+		// a jolt may last a few samples; count it once
+		if d.inJolt {
+			return nil
+		}
+		d.inJolt = true
+
+		d.jolts = append(d.jolts, sample.Time)
+		if len(d.jolts) >= d.settings.ShakeCount {
+			d.jolts = nil
+			return []Event{Shaken}
+		}
+		return nil
+	}
+	d.inJolt = false
+
+	if sample.Time.Before(d.quietUntil) {
+		return nil
+	}
+
+	direction, tilted := d.tiltDirection(sample)
+	if !tilted {
+		d.tilted = false
+		return nil
+	}
+
+	if !d.tilted || direction != d.direction {
+		d.tilted = true
+		d.direction = direction
+		d.nextMove = sample.Time.Add(d.settings.TiltRepeat)
+		return []Event{direction}
+	}
+
+	if sample.Time.Before(d.nextMove) {
+		return nil
+	}
+
+	d.nextMove = sample.Time.Add(d.settings.TiltRepeat)
+	return []Event{direction}
+}
+
+// tiltDirection returns the direction of the axis that is tilted the most, if it's tilted past the threshold
+func (d *MotionDetector) tiltDirection(sample AccelSample) (Event, bool) {
+	x, y := sample.X, sample.Y
+	if math.Max(math.Abs(x), math.Abs(y)) < d.settings.TiltThreshold {
+		return 0, false
+	}
+
+	if math.Abs(x) >= math.Abs(y) {
+		if x > 0 {
+			return MoveRight, true
+		}
+		return MoveLeft, true
+	}
+
+	if y > 0 {
+		return MoveDown, true
+	}
+	return MoveUp, true
+}
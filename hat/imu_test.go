@@ -0,0 +1,134 @@
+package hat
+
+import (
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the IMU", func() {
+	Context("test the accelerometer", func() {
+		origFunc := getIIODevicesPath
+
+		AfterEach(func() {
+			getIIODevicesPath = origFunc
+		})
+
+		It("should read the acceleration in g", func() {
+			getIIODevicesPath = func() string {
+				return path.Join(getTestFileLocation(), "iio")
+			}
+
+			accel, err := OpenAccelerometer()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(accel.dir).Should(HaveSuffix("iio:device1"))
+
+			sample, err := accel.Read()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sample.X).Should(BeNumerically("~", 0.5, 0.001))
+			Expect(sample.Y).Should(BeNumerically("~", -0.25, 0.001))
+			Expect(sample.Z).Should(BeNumerically("~", 1, 0.001))
+		})
+
+		It("should return error if the accelerometer is not found", func() {
+			getIIODevicesPath = func() string {
+				return path.Join(getTestFileLocation(), "graphics")
+			}
+
+			_, err := OpenAccelerometer()
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("test the motion detector", func() {
+		var (
+			d   *MotionDetector
+			now time.Time
+		)
+
+		feed := func(x, y, z float64) []Event {
+			return d.Feed(AccelSample{X: x, Y: y, Z: z, Time: now})
+		}
+
+		BeforeEach(func() {
+			d = NewMotionDetector(DefaultMotionSettings)
+			now = time.Unix(1000, 0)
+		})
+
+		It("should ignore small tilts", func() {
+			Expect(feed(0, 0, 1)).Should(BeEmpty())
+			Expect(feed(0.2, -0.2, 0.96)).Should(BeEmpty())
+		})
+
+		DescribeTable("should move to the lower side", func(x, y float64, expected Event) {
+			Expect(feed(x, y, 0.9)).Should(Equal([]Event{expected}))
+		},
+			Entry("right", 0.4, 0.1, MoveRight),
+			Entry("left", -0.4, 0.1, MoveLeft),
+			Entry("down", 0.1, 0.4, MoveDown),
+			Entry("up", 0.1, -0.4, MoveUp),
+		)
+
+		It("should repeat the move while tilted", func() {
+			Expect(feed(0.5, 0, 0.86)).Should(Equal([]Event{MoveRight}))
+
+			now = now.Add(DefaultMotionSettings.TiltRepeat / 2)
+			Expect(feed(0.5, 0, 0.86)).Should(BeEmpty())
+
+			now = now.Add(DefaultMotionSettings.TiltRepeat / 2)
+			Expect(feed(0.5, 0, 0.86)).Should(Equal([]Event{MoveRight}))
+
+			By("change the direction")
+			now = now.Add(imuPollInterval)
+			Expect(feed(0, -0.5, 0.86)).Should(Equal([]Event{MoveUp}))
+
+			By("level the HAT")
+			now = now.Add(imuPollInterval)
+			Expect(feed(0, 0, 1)).Should(BeEmpty())
+			now = now.Add(imuPollInterval)
+			Expect(feed(0, -0.5, 0.86)).Should(Equal([]Event{MoveUp}))
+		})
+
+		It("should send shaken after enough jolts", func() {
+			Expect(feed(2, 0, 1)).Should(BeEmpty())
+			now = now.Add(imuPollInterval)
+			Expect(feed(2.2, 0, 1)).Should(BeEmpty(), "the same jolt")
+			now = now.Add(imuPollInterval)
+			Expect(feed(0, 0, 1)).Should(BeEmpty())
+
+			now = now.Add(imuPollInterval)
+			Expect(feed(-2, 0, 1)).Should(BeEmpty())
+			now = now.Add(imuPollInterval)
+			Expect(feed(0.5, 0, 0.9)).Should(BeEmpty(), "tilt is ignored while shaking")
+
+			now = now.Add(imuPollInterval)
+			Expect(feed(2, 0, 1)).Should(Equal([]Event{Shaken}))
+
+			By("ignore the tilt until the HAT is still")
+			now = now.Add(imuPollInterval)
+			Expect(feed(0.5, 0, 0.9)).Should(BeEmpty())
+			now = now.Add(DefaultMotionSettings.ShakeWindow)
+			Expect(feed(0.5, 0, 0.9)).Should(Equal([]Event{MoveRight}))
+		})
+
+		It("should forget old jolts", func() {
+			Expect(feed(2, 0, 1)).Should(BeEmpty())
+			now = now.Add(imuPollInterval)
+			Expect(feed(0, 0, 1)).Should(BeEmpty())
+			now = now.Add(imuPollInterval)
+			Expect(feed(2, 0, 1)).Should(BeEmpty())
+			now = now.Add(imuPollInterval)
+			Expect(feed(0, 0, 1)).Should(BeEmpty())
+
+			now = now.Add(DefaultMotionSettings.ShakeWindow)
+			Expect(feed(2, 0, 1)).Should(BeEmpty())
+		})
+
+		It("should detect free fall as a jolt", func() {
+			Expect(feed(0, 0, 0)).Should(BeEmpty())
+			Expect(d.jolts).Should(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,105 @@
+package hat
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// DeviceEvent is a joystick event of an input device. Device is the device number; the HAT joystick is device 0, and
+// the additional input devices are numbered from 1.
+type DeviceEvent struct {
+	Device int
+	Event  Event
+}
+
+// InputReader reads an additional joystick or gamepad, and sends its joystick events, including the gestures. The
+// events are not mapped to the HAT orientation.
+type InputReader struct {
+	device   int
+	selector InputSelector
+	events   chan<- DeviceEvent
+	done     chan struct{}
+	input    io.ReadCloser
+	keys     chan KeyEvent
+}
+
+func NewInputReader(device int, selector InputSelector, events chan<- DeviceEvent) *InputReader {
+	return &InputReader{
+		device:   device,
+		selector: selector,
+		events:   events,
+		done:     make(chan struct{}),
+		keys:     make(chan KeyEvent, 4),
+	}
+}
+
+func (r *InputReader) Start() error {
+	deviceFile, err := findInputDeviceFile(r.selector)
+	if err != nil {
+		return fmt.Errorf("can't find the device event file for the input device %s; %w", r.selector, err)
+	}
+
+	r.input, err = os.Open(deviceFile)
+	if err != nil {
+		return fmt.Errorf("can't open '%s'; %w", deviceFile, err)
+	}
+
+	go r.readKeys()
+	go r.do()
+
+	return nil
+}
+
+func (r *InputReader) Stop() {
+	close(r.done)
+}
+
+func (r *InputReader) readKeys() {
+	err := readKeyEvents(r.input, r.keys, r.done)
+	if err == nil {
+		return
+	}
+
+	select {
+	case <-r.done: // the device was closed by Stop
+	default:
+		log.Printf("Can't read the input device %s; %v", r.selector, err)
+	}
+}
+
+func (r *InputReader) do() {
+	// stop reading the device
+	defer r.input.Close()
+
+	gestures := NewGestureRecognizer(DefaultGestureTiming, time.Now)
+	timer := newGestureTimer()
+	defer timer.stop()
+
+	for {
+		var events []Event
+
+		select {
+		case keyEvent := <-r.keys:
+			events = gestures.Feed(keyEvent)
+
+		case <-timer.c:
+			events = gestures.Tick()
+
+		case <-r.done:
+			return
+		}
+
+		for _, event := range events {
+			select {
+			case r.events <- DeviceEvent{Device: r.device, Event: event}:
+			case <-r.done:
+				return
+			}
+		}
+
+		timer.reset(gestures.NextDeadline())
+	}
+}
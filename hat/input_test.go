@@ -0,0 +1,34 @@
+package hat
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the input reader", func() {
+	It("should send the events with the device number", func() {
+		buf := &bytes.Buffer{}
+		writeInputEvent(buf, 0, 0, evKey, keyDown, 1)
+		writeInputEvent(buf, 0, 0, evKey, keyDown, 0)
+
+		fileName := path.Join(GinkgoT().TempDir(), "events")
+		Expect(os.WriteFile(fileName, buf.Bytes(), 0644)).To(Succeed())
+
+		events := make(chan DeviceEvent, 1)
+		r := NewInputReader(2, InputSelector{Path: fileName}, events)
+		Expect(r.Start()).To(Succeed())
+		defer r.Stop()
+
+		Eventually(events).WithTimeout(time.Second).Should(Receive(Equal(DeviceEvent{Device: 2, Event: MoveDown})))
+	})
+
+	It("should return error if the device is not found", func() {
+		r := NewInputReader(1, InputSelector{Path: path.Join(GinkgoT().TempDir(), "missing")}, make(chan DeviceEvent))
+		Expect(r.Start()).ShouldNot(Succeed())
+	})
+})
@@ -0,0 +1,91 @@
+package hat
+
+import (
+	"time"
+)
+
+// DefaultFrameInterval is the minimum time between two display frames; about 30 frames per second
+const DefaultFrameInterval = time.Second / 30
+
+// FrameLimiter passes the display messages from its input to its output, at most one message per interval. The
+// messages that are received while waiting are coalesced, so only the most recent one is sent. A message that is equal
+// to the last sent message is skipped.
+type FrameLimiter struct {
+	input    <-chan DisplayMessage
+	output   chan<- DisplayMessage
+	interval time.Duration
+}
+
+func NewFrameLimiter(input <-chan DisplayMessage, output chan<- DisplayMessage, interval time.Duration) *FrameLimiter {
+	return &FrameLimiter{
+		input:    input,
+		output:   output,
+		interval: interval,
+	}
+}
+
+// Start passes the messages until the input channel is closed. Then, the pending message is sent, and the output
+// channel is closed.
+func (l *FrameLimiter) Start() {
+	go l.do()
+}
+
+func (l *FrameLimiter) do() {
+	defer close(l.output)
+
+	var (
+		last    *DisplayMessage
+		pending *DisplayMessage
+		next    time.Time
+		timer   *time.Timer
+		wait    <-chan time.Time
+	)
+
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	send := func() {
+		l.output <- *pending
+		last, pending = pending, nil
+		next = time.Now().Add(l.interval)
+	}
+
+	for {
+		select {
+		case msg, ok := <-l.input:
+			if !ok {
+				if pending != nil {
+					send()
+				}
+				return
+			}
+
+			if last != nil && msg.Equal(*last) {
+				// the display is already showing this message; a pending message is not relevant anymore
+				pending = nil
+				continue
+			}
+			pending = &msg
+
+			if wait != nil {
+				continue
+			}
+
+			if d := time.Until(next); d > 0 {
+				timer = time.NewTimer(d)
+				wait = timer.C
+			} else {
+				send()
+			}
+
+		case <-wait:
+			wait = nil
+			if pending != nil {
+				send()
+			}
+		}
+	}
+}
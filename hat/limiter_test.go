@@ -0,0 +1,112 @@
+package hat
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test the frame limiter", func() {
+	It("should compare the display messages", func() {
+		settings := NewDisplaySettings()
+		otherSettings := NewDisplaySettings()
+
+		msg := NewDisplayMessage(newTestScreen(0x404040), 2, 3)
+		msg.Settings = &settings
+
+		same := NewDisplayMessage(newTestScreen(0x404040), 2, 3)
+		same.Settings = &otherSettings
+		Expect(msg.Equal(same)).Should(BeTrue(), "the settings are compared by value")
+
+		other := same
+		other.Screen = newTestScreen(0x404040)
+		other.Screen[7][7] = 0x404041
+		Expect(msg.Equal(other)).Should(BeFalse())
+
+		other = same
+		other.CursorX = 3
+		Expect(msg.Equal(other)).Should(BeFalse())
+
+		other = same
+		other.Settings = nil
+		Expect(msg.Equal(other)).Should(BeFalse())
+
+		dimmed := NewDisplaySettings()
+		dimmed.Brightness = 50
+		other.Settings = &dimmed
+		Expect(msg.Equal(other)).Should(BeFalse())
+	})
+
+	Context("test limiting", func() {
+		var (
+			input  chan DisplayMessage
+			output chan DisplayMessage
+		)
+
+		const interval = 50 * time.Millisecond
+
+		BeforeEach(func() {
+			input = make(chan DisplayMessage)
+			output = make(chan DisplayMessage, 64)
+			NewFrameLimiter(input, output, interval).Start()
+		})
+
+		msgOf := func(c common.Color) DisplayMessage {
+			return NewDisplayMessage(newTestScreen(c), 0, 0)
+		}
+
+		It("should skip identical messages", func() {
+			input <- msgOf(1)
+			Eventually(output).Should(Receive(Equal(msgOf(1))))
+
+			input <- msgOf(1)
+			Consistently(output, 2*interval).ShouldNot(Receive())
+
+			close(input)
+			Eventually(output).Should(BeClosed())
+		})
+
+		It("should coalesce a burst of messages", func() {
+			start := time.Now()
+			for c := common.Color(1); c <= 10; c++ {
+				input <- msgOf(c)
+			}
+
+			Eventually(output).Should(Receive(Equal(msgOf(1))), "the first message is sent immediately")
+
+			var msg DisplayMessage
+			Eventually(output).Should(Receive(&msg))
+			Expect(msg).Should(Equal(msgOf(10)), "only the last message is sent after the interval")
+			Expect(time.Since(start)).Should(BeNumerically(">=", interval))
+			Consistently(output, 2*interval).ShouldNot(Receive())
+
+			close(input)
+			Eventually(output).Should(BeClosed())
+		})
+
+		It("should drop a pending message, if the display is back to the last message", func() {
+			input <- msgOf(1)
+			Eventually(output).Should(Receive())
+
+			input <- msgOf(2)
+			input <- msgOf(1)
+			Consistently(output, 2*interval).ShouldNot(Receive())
+
+			close(input)
+			Eventually(output).Should(BeClosed())
+		})
+
+		It("should send the pending message when closed", func() {
+			input <- msgOf(1)
+			input <- msgOf(2)
+			close(input)
+
+			Eventually(output).Should(Receive(Equal(msgOf(1))))
+			Eventually(output).Should(Receive(Equal(msgOf(2))))
+			Eventually(output).Should(BeClosed())
+		})
+	})
+})
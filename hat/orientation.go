@@ -0,0 +1,74 @@
+package hat
+
+import (
+	"fmt"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// Orientation is how the HAT is mounted. The display is rotated clockwise by Rotation degrees, and then flipped, and
+// the joystick directions are remapped to match, so moving the joystick up always moves the cursor up on the display.
+type Orientation struct {
+	// Rotation is 0, 90, 180 or 270
+	Rotation int
+	// FlipH mirrors the display left to right
+	FlipH bool
+	// FlipV mirrors the display top to bottom
+	FlipV bool
+}
+
+func (o Orientation) Validate() error {
+	switch o.Rotation {
+	case 0, 90, 180, 270:
+		return nil
+	}
+	return fmt.Errorf("wrong rotation %d; should be one of 0, 90, 180 or 270", o.Rotation)
+}
+
+// the direction of each move event, as a vector
+var moveVectors = map[Event][2]int{
+	MoveUp:    {0, -1},
+	MoveLeft:  {-1, 0},
+	MoveDown:  {0, 1},
+	MoveRight: {1, 0},
+}
+
+// transformVector rotates and flips a vector
+func (o Orientation) transformVector(x, y int) (int, int) {
+	for i := 0; i < o.Rotation/90; i++ {
+		x, y = -y, x
+	}
+
+	if o.FlipH {
+		x = -x
+	}
+	if o.FlipV {
+		y = -y
+	}
+
+	return x, y
+}
+
+// transform returns the position on the LED matrix of the display pixel at x, y. The position is transformed as a
+// vector from the center of the matrix; the coordinates are doubled, so the center is an integer.
+func (o Orientation) transform(x, y int) (int, int) {
+	const last = common.WindowSize - 1
+	x, y = o.transformVector(2*x-last, 2*y-last)
+	return (x + last) / 2, (y + last) / 2
+}
+
+// MapEvent returns the display direction of the joystick direction. Other events are returned as is.
+func (o Orientation) MapEvent(e Event) Event {
+	v, ok := moveVectors[e]
+	if !ok {
+		return e
+	}
+
+	for event, eventVector := range moveVectors {
+		if x, y := o.transformVector(eventVector[0], eventVector[1]); x == v[0] && y == v[1] {
+			return event
+		}
+	}
+
+	return e
+}
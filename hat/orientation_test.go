@@ -0,0 +1,93 @@
+package hat
+
+import (
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the orientation", func() {
+	DescribeTable("should transform the display position", func(o Orientation, x, y, expectedX, expectedY int) {
+		tx, ty := o.transform(x, y)
+		Expect(tx).Should(Equal(expectedX))
+		Expect(ty).Should(Equal(expectedY))
+	},
+		Entry("no rotation", Orientation{}, 1, 2, 1, 2),
+		Entry("90", Orientation{Rotation: 90}, 0, 0, 7, 0),
+		Entry("90, top right", Orientation{Rotation: 90}, 7, 0, 7, 7),
+		Entry("90, inner pixel", Orientation{Rotation: 90}, 1, 2, 5, 1),
+		Entry("180", Orientation{Rotation: 180}, 1, 2, 6, 5),
+		Entry("270", Orientation{Rotation: 270}, 1, 2, 2, 6),
+		Entry("horizontal flip", Orientation{FlipH: true}, 1, 2, 6, 2),
+		Entry("vertical flip", Orientation{FlipV: true}, 1, 2, 1, 5),
+		Entry("90 and horizontal flip", Orientation{Rotation: 90, FlipH: true}, 1, 2, 2, 1),
+	)
+
+	DescribeTable("should map the joystick directions", func(o Orientation, event, expected Event) {
+		Expect(o.MapEvent(event)).Should(Equal(expected))
+	},
+		Entry("no rotation", Orientation{}, MoveUp, MoveUp),
+		Entry("90, right is up", Orientation{Rotation: 90}, MoveRight, MoveUp),
+		Entry("90, down is right", Orientation{Rotation: 90}, MoveDown, MoveRight),
+		Entry("180, up is down", Orientation{Rotation: 180}, MoveUp, MoveDown),
+		Entry("270, left is up", Orientation{Rotation: 270}, MoveLeft, MoveUp),
+		Entry("horizontal flip, left is right", Orientation{FlipH: true}, MoveLeft, MoveRight),
+		Entry("horizontal flip, up is up", Orientation{FlipH: true}, MoveUp, MoveUp),
+		Entry("vertical flip, up is down", Orientation{FlipV: true}, MoveUp, MoveDown),
+		Entry("button", Orientation{Rotation: 90}, Pressed, Pressed),
+	)
+
+	It("should move the cursor towards the joystick direction", func() {
+		for _, rotation := range []int{0, 90, 180, 270} {
+			for _, flipH := range []bool{false, true} {
+				for _, flipV := range []bool{false, true} {
+					o := Orientation{Rotation: rotation, FlipH: flipH, FlipV: flipV}
+					for physical, v := range moveVectors {
+						logical := moveVectors[o.MapEvent(physical)]
+						// the cursor moves on the LED matrix, in the direction the joystick was moved to
+						fromX, fromY := o.transform(3, 3)
+						toX, toY := o.transform(3+logical[0], 3+logical[1])
+						Expect([2]int{toX - fromX, toY - fromY}).Should(Equal(v), "orientation %+v, event %s", o, physical)
+					}
+				}
+			}
+		}
+	})
+
+	DescribeTable("should validate the rotation", func(rotation int, valid bool) {
+		err := Orientation{Rotation: rotation}.Validate()
+		if valid {
+			Expect(err).ShouldNot(HaveOccurred())
+		} else {
+			Expect(err).Should(HaveOccurred())
+		}
+	},
+		Entry("0", 0, true),
+		Entry("90", 90, true),
+		Entry("180", 180, true),
+		Entry("270", 270, true),
+		Entry("45", 45, false),
+		Entry("360", 360, false),
+		Entry("-90", -90, false),
+	)
+
+	It("should draw the rotated display", func() {
+		fileName := path.Join(GinkgoT().TempDir(), "fb")
+		Expect(os.WriteFile(fileName, nil, 0644)).To(Succeed())
+
+		h := NewHat(make(chan Event), make(chan DisplayMessage), Options{Orientation: Orientation{Rotation: 180}})
+		h.fb = NewFrameBuffer(fileName)
+
+		msg := NewDisplayMessage(newTestScreen(0), 1, 0)
+		msg.Screen[0][0] = 0xFFFFFF
+		h.drawScreen(msg)
+
+		data, err := os.ReadFile(fileName)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(data).Should(HaveLen(128))
+		Expect(data[122:128]).Should(Equal([]byte{0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}))
+		Expect(data[0:122]).Should(Equal(make([]byte, 122)))
+	})
+})
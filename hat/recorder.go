@@ -0,0 +1,76 @@
+package hat
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// Recorder writes the display messages to a file, one JSON frame per line. The screen is recorded with the cursor, as
+// the LED matrix shows it. Each frame is written directly to the file, so the recording is complete even if the
+// application is killed. Close the recorder to flush the file to the disk.
+type Recorder struct {
+	enc  *json.Encoder
+	file *os.File
+	now  Clock
+}
+
+type recordedFrame struct {
+	Time    time.Time        `json:"time"`
+	Screen  [][]common.Color `json:"screen"`
+	CursorX uint8            `json:"cursorX"`
+	CursorY uint8            `json:"cursorY"`
+	WindowX uint8            `json:"windowX"`
+	WindowY uint8            `json:"windowY"`
+}
+
+// NewRecorder creates the recording file. An existing file is truncated.
+func NewRecorder(fileName string) (*Recorder, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRecorder(f, time.Now)
+	r.file = f
+	return r, nil
+}
+
+func newRecorder(out io.Writer, clock Clock) *Recorder {
+	return &Recorder{enc: json.NewEncoder(out), now: clock}
+}
+
+// Close syncs and closes the recording file
+func (r *Recorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Sync()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (r *Recorder) Display(msg DisplayMessage) error {
+	screen := make([][]common.Color, common.WindowSize)
+	for y := range screen {
+		screen[y] = make([]common.Color, common.WindowSize)
+		for x := range screen[y] {
+			screen[y][x] = msg.pixelColor(x, y, true)
+		}
+	}
+
+	return r.enc.Encode(recordedFrame{
+		Time:    r.now(),
+		Screen:  screen,
+		CursorX: msg.CursorX,
+		CursorY: msg.CursorY,
+		WindowX: msg.WindowX,
+		WindowY: msg.WindowY,
+	})
+}
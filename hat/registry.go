@@ -0,0 +1,91 @@
+package hat
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// HAT backend names
+const (
+	AutoName     = "auto"
+	SenseHatName = "sensehat"
+	TerminalName = "terminal"
+	HeadlessName = "none"
+)
+
+// Options is the configuration of the HAT backends
+type Options struct {
+	// Input selects the joystick input device
+	Input InputSelector
+	// Orientation is how the Sense HAT is mounted
+	Orientation Orientation
+	// IMU enables the motion gestures of the Sense HAT accelerometer
+	IMU bool
+}
+
+// Factory creates a HAT backend, that sends the joystick events to joystickEvents and displays the messages from
+// screenEvents
+type Factory func(joystickEvents chan<- Event, screenEvents <-chan DisplayMessage, opts Options) Interface
+
+var registry = map[string]Factory{}
+
+func init() {
+	Register(SenseHatName, func(je chan<- Event, se <-chan DisplayMessage, opts Options) Interface {
+		return NewHat(je, se, opts)
+	})
+	Register(TerminalName, func(je chan<- Event, se <-chan DisplayMessage, _ Options) Interface {
+		return NewTerminal(je, se)
+	})
+	Register(HeadlessName, func(je chan<- Event, se <-chan DisplayMessage, _ Options) Interface {
+		return NewHeadless(je, se)
+	})
+}
+
+// Register adds a named HAT backend. Registering an existing name replaces the previous backend.
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+// Names returns the names of the registered HAT backends, and the auto detection name
+func Names() []string {
+	names := make([]string, 0, len(registry)+1)
+	names = append(names, AutoName)
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+
+	return names
+}
+
+// New creates the named HAT backend. The "auto" name selects the Sense HAT if it is found, or the headless backend
+// if it's not.
+func New(name string, joystickEvents chan<- Event, screenEvents <-chan DisplayMessage, opts Options) (Interface, error) {
+	if name == AutoName {
+		name = detect(opts.Input)
+	}
+
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf(`unknown HAT "%s"; should be one of %s`, name, strings.Join(Names(), ", "))
+	}
+
+	return factory(joystickEvents, screenEvents, opts), nil
+}
+
+func detect(input InputSelector) string {
+	if _, err := findInputDeviceFile(input); err != nil {
+		log.Printf("Can't find the joystick input device %s; running without a HAT", input)
+		return HeadlessName
+	}
+
+	if _, err := findFrameBufferDevice(); err != nil {
+		log.Println("Can't find the Sense HAT display; running without a HAT")
+		return HeadlessName
+	}
+
+	log.Println("Found the Sense HAT")
+	return SenseHatName
+}
@@ -0,0 +1,87 @@
+package hat
+
+import (
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test the HAT registry", func() {
+	origDevicesFilePath := getDevicesFilePath
+	origGraphicsClassPath := getGraphicsClassPath
+
+	BeforeEach(func() {
+		getDevicesFilePath = func() string {
+			return path.Join(getTestFileLocation(), "validDeviceFile.txt")
+		}
+		getGraphicsClassPath = func() string {
+			return path.Join(getTestFileLocation(), "graphics")
+		}
+	})
+
+	AfterEach(func() {
+		getDevicesFilePath = origDevicesFilePath
+		getGraphicsClassPath = origGraphicsClassPath
+	})
+
+	It("should list the backends", func() {
+		Expect(Names()).Should(Equal([]string{AutoName, HeadlessName, SenseHatName, TerminalName}))
+	})
+
+	DescribeTable("should create the backend by its name", func(name string, expected Interface) {
+		h, err := New(name, make(chan Event), make(chan DisplayMessage), Options{Input: DefaultInputSelector})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(h).Should(BeAssignableToTypeOf(expected))
+	},
+		Entry("sense HAT", SenseHatName, &Hat{}),
+		Entry("terminal", TerminalName, &Terminal{}),
+		Entry("headless", HeadlessName, &Headless{}),
+	)
+
+	It("should reject unknown backends", func() {
+		_, err := New("wrong", make(chan Event), make(chan DisplayMessage), Options{Input: DefaultInputSelector})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("should detect the Sense HAT", func() {
+		h, err := New(AutoName, make(chan Event), make(chan DisplayMessage), Options{Input: DefaultInputSelector})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(h).Should(BeAssignableToTypeOf(&Hat{}))
+	})
+
+	It("should fall back to headless if there is no joystick", func() {
+		getDevicesFilePath = func() string {
+			return path.Join(getTestFileLocation(), "noFoundDeviceFile.txt")
+		}
+
+		h, err := New(AutoName, make(chan Event), make(chan DisplayMessage), Options{Input: DefaultInputSelector})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(h).Should(BeAssignableToTypeOf(&Headless{}))
+	})
+
+	It("should fall back to headless if there is no frame buffer", func() {
+		getGraphicsClassPath = func() string {
+			return path.Join(getTestFileLocation(), "notExists")
+		}
+
+		h, err := New(AutoName, make(chan Event), make(chan DisplayMessage), Options{Input: DefaultInputSelector})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(h).Should(BeAssignableToTypeOf(&Headless{}))
+	})
+
+	Context("test the headless backend", func() {
+		It("should discard the display messages and close the events on stop", func() {
+			je := make(chan Event)
+			se := make(chan DisplayMessage)
+
+			h := NewHeadless(je, se)
+			Expect(h.Start()).To(Succeed())
+
+			se <- NewDisplayMessage(newTestScreen(0), 0, 0)
+
+			h.Stop()
+			Eventually(je).Should(BeClosed())
+		})
+	})
+})
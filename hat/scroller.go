@@ -0,0 +1,165 @@
+package hat
+
+import (
+	"time"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+const (
+	scrollInterval = 80 * time.Millisecond
+	// maxQueuedTexts is the number of texts that may wait for the scrolling text; more texts are dropped
+	maxQueuedTexts = 3
+	// MaxTextLength is the maximum number of characters in a text message
+	MaxTextLength = 64
+)
+
+// TextMessage is a short text to scroll on the display
+type TextMessage struct {
+	Text  string       `json:"text"`
+	Color common.Color `json:"color"`
+}
+
+// Scroller passes the display messages from its input to its output. When it gets a text message, it takes over the
+// display and scrolls the text from right to left. Then it restores the last display message it got, including the
+// ones that were received while the text was scrolling.
+type Scroller struct {
+	input    <-chan DisplayMessage
+	texts    <-chan TextMessage
+	output   chan<- DisplayMessage
+	interval time.Duration
+}
+
+func NewScroller(input <-chan DisplayMessage, texts <-chan TextMessage, output chan<- DisplayMessage) *Scroller {
+	return &Scroller{
+		input:    input,
+		texts:    texts,
+		output:   output,
+		interval: scrollInterval,
+	}
+}
+
+// Start passes the messages until the input channel is closed. Then, the output channel is closed.
+func (s *Scroller) Start() {
+	go s.do()
+}
+
+func (s *Scroller) do() {
+	defer close(s.output)
+
+	var (
+		last    DisplayMessage
+		queue   []TextMessage
+		current *textScroll
+		ticker  *time.Ticker
+		tick    <-chan time.Time
+	)
+
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	start := func(text TextMessage) {
+		current = newTextScroll(text)
+		if ticker == nil {
+			ticker = time.NewTicker(s.interval)
+			tick = ticker.C
+		}
+		s.output <- current.frame(last)
+	}
+
+	for {
+		select {
+		case msg, ok := <-s.input:
+			if !ok {
+				return
+			}
+
+			last = msg
+			if current == nil {
+				s.output <- msg
+			}
+
+		case text := <-s.texts:
+			if current == nil {
+				start(text)
+			} else if len(queue) < maxQueuedTexts {
+				queue = append(queue, text)
+			}
+
+		case <-tick:
+			if current.next() {
+				s.output <- current.frame(last)
+				continue
+			}
+
+			if len(queue) > 0 {
+				text := queue[0]
+				queue = queue[1:]
+				start(text)
+				continue
+			}
+
+			ticker.Stop()
+			ticker, tick, current = nil, nil, nil
+			if last.Screen != nil {
+				s.output <- last
+			}
+		}
+	}
+}
+
+// textScroll is the state of a scrolling text. The text columns are padded with an empty window on both sides, so the
+// text enters the display from the right, and leaves it to the left.
+type textScroll struct {
+	columns []byte
+	color   common.Color
+	pos     int
+}
+
+func newTextScroll(text TextMessage) *textScroll {
+	runes := []rune(text.Text)
+	if len(runes) > MaxTextLength {
+		runes = runes[:MaxTextLength]
+	}
+
+	columns := make([]byte, common.WindowSize)
+	columns = append(columns, textColumns(string(runes))...)
+	columns = append(columns, make([]byte, common.WindowSize)...)
+
+	return &textScroll{columns: columns, color: text.Color}
+}
+
+// next moves the text one column to the left. It returns false when the text has left the display.
+func (t *textScroll) next() bool {
+	if t.pos+common.WindowSize >= len(t.columns) {
+		return false
+	}
+
+	t.pos++
+	return true
+}
+
+// frame builds the display message of the current position. It keeps the display settings of the last message.
+func (t *textScroll) frame(last DisplayMessage) DisplayMessage {
+	screen := make([][]common.Color, common.WindowSize)
+	for y := range screen {
+		screen[y] = make([]common.Color, common.WindowSize)
+		for x := range screen[y] {
+			if t.columns[t.pos+x]&(1<<y) != 0 {
+				screen[y][x] = t.color
+			}
+		}
+	}
+
+	return DisplayMessage{
+		Screen:   screen,
+		WindowX:  last.WindowX,
+		WindowY:  last.WindowY,
+		Settings: last.Settings,
+		Zoom:     1,
+		NoCursor: true,
+	}
+}
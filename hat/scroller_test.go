@@ -0,0 +1,113 @@
+package hat
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test the text scroller", func() {
+	It("should render the text columns", func() {
+		Expect(textColumns("!")).Should(Equal([]byte{0x5F}))
+		Expect(textColumns("i.")).Should(Equal([]byte{0x44, 0x7D, 0x40, 0, 0x60, 0x60}))
+		Expect(textColumns("a b")).Should(HaveLen(5 + letterSpace + spaceWidth + letterSpace + 5))
+		Expect(glyph('é')).Should(Equal(glyph('?')), "unknown characters are displayed as '?'")
+	})
+
+	Context("test scrolling", func() {
+		var (
+			input  chan DisplayMessage
+			texts  chan TextMessage
+			output chan DisplayMessage
+		)
+
+		BeforeEach(func() {
+			input = make(chan DisplayMessage)
+			texts = make(chan TextMessage)
+			output = make(chan DisplayMessage, 64)
+
+			s := NewScroller(input, texts, output)
+			s.interval = time.Millisecond
+			s.Start()
+		})
+
+		AfterEach(func() {
+			close(input)
+			Eventually(output).Should(BeClosed())
+		})
+
+		// readText reads the frames of a scrolling text, until the display message is restored
+		readText := func() []DisplayMessage {
+			var frames []DisplayMessage
+			for msg := range output {
+				if !msg.NoCursor {
+					return append(frames, msg)
+				}
+				frames = append(frames, msg)
+			}
+			return frames
+		}
+
+		It("should scroll the text, and restore the display", func() {
+			msg := NewDisplayMessage(newTestScreen(0x404040), 2, 3)
+			settings := NewDisplaySettings()
+			msg.Settings = &settings
+
+			input <- msg
+			Expect(<-output).Should(Equal(msg))
+
+			texts <- TextMessage{Text: "!", Color: 0xFF0000}
+			frames := readText()
+
+			// the text column moves from the right of the display to its left, and then the display is restored
+			Expect(frames).Should(HaveLen(common.WindowSize + 2 + 1))
+			Expect(frames[0].Settings).Should(Equal(&settings))
+			for y, line := range frames[0].Screen {
+				Expect(line).Should(Equal(make([]common.Color, common.WindowSize)), "line %d", y)
+			}
+
+			// after 8 moves, the "!" column is at the left edge
+			Expect(frames[8].Screen[0][0]).Should(Equal(common.Color(0xFF0000)))
+			Expect(frames[8].Screen[5][0]).Should(Equal(common.Color(0)))
+			Expect(frames[8].Screen[6][0]).Should(Equal(common.Color(0xFF0000)))
+			Expect(frames[8].pixelColor(2, 3, true)).Should(Equal(common.Color(0)), "no cursor while scrolling")
+
+			Expect(frames[len(frames)-1]).Should(Equal(msg))
+		})
+
+		It("should restore the most recent display message", func() {
+			input <- NewDisplayMessage(newTestScreen(0), 0, 0)
+			<-output
+
+			texts <- TextMessage{Text: "hi", Color: 0xFFFFFF}
+			Expect((<-output).NoCursor).Should(BeTrue())
+
+			updated := NewDisplayMessage(newTestScreen(0x123456), 4, 5)
+			input <- updated
+
+			frames := readText()
+			Expect(frames[len(frames)-1]).Should(Equal(updated))
+			for _, frame := range frames[:len(frames)-1] {
+				Expect(frame.NoCursor).Should(BeTrue())
+			}
+		})
+
+		It("should scroll the queued texts one after the other", func() {
+			msg := NewDisplayMessage(newTestScreen(0), 0, 0)
+			input <- msg
+			<-output
+
+			texts <- TextMessage{Text: "!", Color: 0xFF0000}
+			texts <- TextMessage{Text: "!", Color: 0x00FF00}
+
+			frames := readText()
+			Expect(frames).Should(HaveLen(2*(common.WindowSize+2) + 1))
+			Expect(frames[8].Screen[0][0]).Should(Equal(common.Color(0xFF0000)))
+			Expect(frames[18].Screen[0][0]).Should(Equal(common.Color(0x00FF00)))
+			Expect(frames[len(frames)-1]).Should(Equal(msg))
+		})
+	})
+})
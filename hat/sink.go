@@ -0,0 +1,288 @@
+package hat
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DisplaySink is an output of the display messages, like the HAT LED matrix, the terminal or a recording file
+type DisplaySink interface {
+	Display(msg DisplayMessage) error
+}
+
+// HealthReporter is implemented by sinks that know their health by themselves, like the HAT backends, that draw the
+// display messages in their own goroutine
+type HealthReporter interface {
+	Health() error
+}
+
+// Display sink names
+const (
+	HatSinkName      = "hat"
+	TerminalSinkName = "terminal"
+	RecordSinkPrefix = "record:"
+)
+
+// sinkStallTimeout is how long a sink may be busy with a single display message, before it's reported as unhealthy
+const sinkStallTimeout = 2 * time.Second
+
+// SinkHealth is the health of a display sink
+type SinkHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// Frames is the number of the displayed messages
+	Frames uint64 `json:"frames"`
+	// Dropped is the number of the messages that were replaced by a newer message, before the sink got to them
+	Dropped   uint64    `json:"dropped"`
+	Errors    uint64    `json:"errors"`
+	LastError string    `json:"lastError,omitempty"`
+	LastFrame time.Time `json:"lastFrame"`
+}
+
+// Fanout sends each display message to all its sinks. Each sink is running in its own goroutine, and only gets the
+// most recent message, so a slow or a failing sink does not block the other sinks, or the sender. When the input
+// channel is closed, the sinks that implement io.Closer are closed, after they displayed their last message.
+type Fanout struct {
+	input        <-chan DisplayMessage
+	lock         sync.Mutex
+	workers      []*sinkWorker
+	running      sync.WaitGroup
+	done         chan struct{}
+	stallTimeout time.Duration
+}
+
+func NewFanout(input <-chan DisplayMessage) *Fanout {
+	return &Fanout{
+		input:        input,
+		done:         make(chan struct{}),
+		stallTimeout: sinkStallTimeout,
+	}
+}
+
+// AddSink adds a named sink. The sink gets the messages that are received after it was added.
+func (f *Fanout) AddSink(name string, sink DisplaySink) {
+	w := &sinkWorker{
+		sink:    sink,
+		pending: make(chan DisplayMessage, 1),
+		health:  SinkHealth{Name: name, Healthy: true},
+	}
+
+	f.lock.Lock()
+	f.workers = append(f.workers, w)
+	f.running.Add(1)
+	f.lock.Unlock()
+
+	go func() {
+		defer f.running.Done()
+		w.do()
+	}()
+}
+
+// ReplaceSink replaces the sink with the given name, and keeps its health counters; e.g. when the HAT backend is
+// replaced by a fallback. If there is no such sink, the sink is added.
+func (f *Fanout) ReplaceSink(name string, sink DisplaySink) {
+	f.lock.Lock()
+	for _, w := range f.workers {
+		if w.health.Name == name {
+			w.setSink(sink)
+			f.lock.Unlock()
+			return
+		}
+	}
+	f.lock.Unlock()
+
+	f.AddSink(name, sink)
+}
+
+// Start sends the messages to the sinks, until the input channel is closed
+func (f *Fanout) Start() {
+	go f.do()
+}
+
+func (f *Fanout) do() {
+	defer close(f.done)
+
+	for msg := range f.input {
+		f.lock.Lock()
+		for _, w := range f.workers {
+			w.offer(msg)
+		}
+		f.lock.Unlock()
+	}
+
+	f.lock.Lock()
+	for _, w := range f.workers {
+		close(w.pending)
+	}
+	f.workers = nil
+	f.lock.Unlock()
+
+	f.running.Wait()
+}
+
+// Done is closed when the fanout was stopped by closing its input channel, and all the sinks are done
+func (f *Fanout) Done() <-chan struct{} {
+	return f.done
+}
+
+// Health returns the health of the sinks, in the order they were added
+func (f *Fanout) Health() []SinkHealth {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	res := make([]SinkHealth, 0, len(f.workers))
+	for _, w := range f.workers {
+		res = append(res, w.getHealth(f.stallTimeout))
+	}
+
+	return res
+}
+
+type sinkWorker struct {
+	sink    DisplaySink
+	pending chan DisplayMessage
+
+	lock      sync.Mutex
+	health    SinkHealth
+	busySince time.Time
+}
+
+// offer replaces the pending message with the new one. It's only called from the fanout goroutine, so there is
+// always room for the new message, after the old one was removed.
+func (w *sinkWorker) offer(msg DisplayMessage) {
+	select {
+	case w.pending <- msg:
+		return
+	default:
+	}
+
+	select {
+	case <-w.pending:
+		w.lock.Lock()
+		w.health.Dropped++
+		w.lock.Unlock()
+	default:
+	}
+
+	w.pending <- msg
+}
+
+func (w *sinkWorker) setSink(sink DisplaySink) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.sink = sink
+}
+
+func (w *sinkWorker) do() {
+	for msg := range w.pending {
+		w.lock.Lock()
+		w.busySince = time.Now()
+		sink := w.sink
+		w.lock.Unlock()
+
+		err := sink.Display(msg)
+
+		w.lock.Lock()
+		w.busySince = time.Time{}
+		if err != nil {
+			w.health.Errors++
+			w.health.LastError = err.Error()
+		} else {
+			w.health.Frames++
+			w.health.LastError = ""
+			w.health.LastFrame = time.Now()
+		}
+		w.lock.Unlock()
+	}
+
+	w.lock.Lock()
+	sink := w.sink
+	w.lock.Unlock()
+
+	if closer, ok := sink.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("failed to close the %s display; %v", w.health.Name, err)
+		}
+	}
+}
+
+func (w *sinkWorker) getHealth(stallTimeout time.Duration) SinkHealth {
+	w.lock.Lock()
+	health := w.health
+	busySince := w.busySince
+	sink := w.sink
+	w.lock.Unlock()
+
+	if !busySince.IsZero() && time.Since(busySince) > stallTimeout {
+		health.LastError = fmt.Sprintf("stalled for %v", time.Since(busySince).Round(time.Millisecond))
+	} else if reporter, ok := sink.(HealthReporter); ok {
+		if err := reporter.Health(); err != nil {
+			health.LastError = err.Error()
+		}
+	}
+
+	health.Healthy = health.LastError == ""
+	return health
+}
+
+// ChannelSink sends the display messages to a HAT backend
+type ChannelSink struct {
+	ch      chan<- DisplayMessage
+	backend Interface
+}
+
+func NewChannelSink(ch chan<- DisplayMessage, backend Interface) *ChannelSink {
+	return &ChannelSink{ch: ch, backend: backend}
+}
+
+func (s *ChannelSink) Display(msg DisplayMessage) error {
+	s.ch <- msg
+	return nil
+}
+
+// Health reports the health of the backend, if it knows it
+func (s *ChannelSink) Health() error {
+	if reporter, ok := s.backend.(HealthReporter); ok {
+		return reporter.Health()
+	}
+	return nil
+}
+
+// NewDisplaySink creates a display sink from its description; "terminal" or "record:<file name>"
+func NewDisplaySink(spec string) (DisplaySink, error) {
+	switch {
+	case spec == TerminalSinkName:
+		return NewTerminalSink(), nil
+
+	case strings.HasPrefix(spec, RecordSinkPrefix):
+		fileName := strings.TrimPrefix(spec, RecordSinkPrefix)
+		if fileName == "" {
+			return nil, fmt.Errorf("missing the recording file name")
+		}
+		return NewRecorder(fileName)
+	}
+
+	return nil, fmt.Errorf(`unknown display "%s"; should be %s or %s<file name>`, spec, TerminalSinkName, RecordSinkPrefix)
+}
+
+// drawStatus is the result of the last drawing of a HAT backend
+type drawStatus struct {
+	lock sync.Mutex
+	err  error
+}
+
+func (s *drawStatus) set(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.err = err
+}
+
+func (s *drawStatus) Health() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
@@ -0,0 +1,244 @@
+package hat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// fakeSink records the displayed messages. When block is not nil, the sink waits for it before each message.
+type fakeSink struct {
+	lock   sync.Mutex
+	cursor []uint8
+	block  chan struct{}
+	err    error
+	closed bool
+}
+
+func (s *fakeSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *fakeSink) Display(msg DisplayMessage) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cursor = append(s.cursor, msg.CursorX)
+	return s.err
+}
+
+func (s *fakeSink) displayed() []uint8 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]uint8{}, s.cursor...)
+}
+
+var _ = Describe("test the display sinks", func() {
+	Context("test the fanout", func() {
+		var (
+			input  chan DisplayMessage
+			fanout *Fanout
+		)
+
+		BeforeEach(func() {
+			input = make(chan DisplayMessage)
+			fanout = NewFanout(input)
+			fanout.stallTimeout = 50 * time.Millisecond
+		})
+
+		AfterEach(func() {
+			close(input)
+		})
+
+		send := func(x uint8) {
+			input <- NewDisplayMessage(newTestScreen(0), x, 0)
+		}
+
+		It("should send the messages to all the sinks", func() {
+			s1, s2 := &fakeSink{}, &fakeSink{}
+			fanout.AddSink("s1", s1)
+			fanout.AddSink("s2", s2)
+			fanout.Start()
+
+			for x := uint8(0); x < 3; x++ {
+				send(x)
+				Eventually(s1.displayed).Should(HaveLen(int(x) + 1))
+				Eventually(s2.displayed).Should(HaveLen(int(x) + 1))
+			}
+			Expect(s1.displayed()).Should(Equal([]uint8{0, 1, 2}))
+			Expect(s2.displayed()).Should(Equal([]uint8{0, 1, 2}))
+
+			health := fanout.Health()
+			Expect(health).Should(HaveLen(2))
+			Expect(health[0].Name).Should(Equal("s1"))
+			Expect(health[0].Healthy).Should(BeTrue())
+			Expect(health[0].Frames).Should(BeEquivalentTo(3))
+			Expect(health[1].Name).Should(Equal("s2"))
+		})
+
+		It("should not wait for a slow sink", func() {
+			slow := &fakeSink{block: make(chan struct{})}
+			fast := &fakeSink{}
+			fanout.AddSink("slow", slow)
+			fanout.AddSink("fast", fast)
+			fanout.Start()
+
+			for x := uint8(0); x < 5; x++ {
+				send(x)
+				Eventually(fast.displayed).Should(HaveLen(int(x) + 1))
+			}
+			Expect(fast.displayed()).Should(Equal([]uint8{0, 1, 2, 3, 4}))
+
+			Eventually(func() bool { return fanout.Health()[0].Healthy }).Should(BeFalse())
+			health := fanout.Health()[0]
+			Expect(health.LastError).Should(HavePrefix("stalled"))
+			Expect(health.Dropped).Should(BeEquivalentTo(3), "the sink is busy with 0, and only 4 is pending")
+
+			By("releasing the slow sink, it should get the most recent message")
+			close(slow.block)
+			Eventually(slow.displayed).Should(Equal([]uint8{0, 4}))
+			Eventually(func() bool { return fanout.Health()[0].Healthy }).Should(BeTrue())
+		})
+
+		It("should report a failing sink", func() {
+			failing := &fakeSink{err: errors.New("fake error")}
+			fanout.AddSink("failing", failing)
+			fanout.Start()
+
+			send(1)
+			Eventually(func() string { return fanout.Health()[0].LastError }).Should(Equal("fake error"))
+			health := fanout.Health()[0]
+			Expect(health.Healthy).Should(BeFalse())
+			Expect(health.Errors).Should(BeEquivalentTo(1))
+
+			failing.lock.Lock()
+			failing.err = nil
+			failing.lock.Unlock()
+
+			send(2)
+			Eventually(func() bool { return fanout.Health()[0].Healthy }).Should(BeTrue())
+		})
+
+		It("should report the health of the HAT backend", func() {
+			ch := make(chan DisplayMessage, 1)
+			backend := NewHeadless(make(chan Event), ch)
+			status := &drawStatus{}
+			fanout.AddSink("hat", NewChannelSink(ch, struct {
+				Interface
+				*drawStatus
+			}{backend, status}))
+			fanout.Start()
+
+			send(3)
+			Eventually(ch).Should(Receive())
+			Expect(fanout.Health()[0].Healthy).Should(BeTrue())
+
+			status.set(errors.New("can't draw"))
+			Expect(fanout.Health()[0].Healthy).Should(BeFalse())
+			Expect(fanout.Health()[0].LastError).Should(Equal("can't draw"))
+		})
+
+		It("should report the health of the replaced HAT backend", func() {
+			ch := make(chan DisplayMessage, 1)
+			fanout.AddSink("hat", NewChannelSink(ch, NewHeadless(make(chan Event), ch)))
+			fanout.Start()
+			Expect(fanout.Health()[0].Healthy).Should(BeTrue())
+
+			fallback := NewHeadlessFallback(make(chan Event), ch, errors.New("no HAT"))
+			fanout.ReplaceSink("hat", NewChannelSink(ch, fallback))
+
+			send(3)
+			Eventually(ch).Should(Receive())
+			health := fanout.Health()
+			Expect(health).Should(HaveLen(1))
+			Expect(health[0].Frames).Should(BeEquivalentTo(1))
+			Expect(health[0].Healthy).Should(BeFalse())
+			Expect(health[0].LastError).Should(Equal("headless fallback: no HAT"))
+		})
+	})
+
+	It("should close the sinks when the input is closed", func() {
+		input := make(chan DisplayMessage)
+		fanout := NewFanout(input)
+		sink := &fakeSink{}
+		fanout.AddSink("sink", sink)
+		fanout.Start()
+
+		input <- NewDisplayMessage(newTestScreen(0), 5, 0)
+		close(input)
+
+		Eventually(fanout.Done()).Should(BeClosed())
+		Expect(sink.displayed()).Should(Equal([]uint8{5}))
+		Expect(sink.closed).Should(BeTrue())
+	})
+
+	It("should close the recording file", func() {
+		fileName := filepath.Join(GinkgoT().TempDir(), "frames.jsonl")
+		r, err := NewRecorder(fileName)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.Display(NewDisplayMessage(newTestScreen(0), 2, 3))).To(Succeed())
+		Expect(r.Close()).To(Succeed())
+		Expect(r.Display(NewDisplayMessage(newTestScreen(0), 2, 3))).ShouldNot(Succeed())
+
+		data, err := os.ReadFile(fileName)
+		Expect(err).ToNot(HaveOccurred())
+		frame := recordedFrame{}
+		Expect(json.Unmarshal(data, &frame)).To(Succeed())
+		Expect(frame.CursorY).Should(BeEquivalentTo(3))
+	})
+
+	It("should record the frames with the cursor", func() {
+		buf := &bytes.Buffer{}
+		now := time.Unix(1000, 0).UTC()
+		r := newRecorder(buf, func() time.Time { return now })
+
+		msg := NewDisplayMessage(newTestScreen(0x404040), 2, 3)
+		msg.WindowX = 5
+		Expect(r.Display(msg)).To(Succeed())
+		Expect(r.Display(msg)).To(Succeed())
+
+		dec := json.NewDecoder(buf)
+		frame := recordedFrame{}
+		Expect(dec.Decode(&frame)).To(Succeed())
+		Expect(frame.Time).Should(Equal(now))
+		Expect(frame.CursorX).Should(BeEquivalentTo(2))
+		Expect(frame.WindowX).Should(BeEquivalentTo(5))
+		Expect(frame.Screen[3][2]).Should(Equal(common.Color(0xBFBFBF)))
+		Expect(frame.Screen[0][0]).Should(Equal(common.Color(0x404040)))
+		Expect(dec.More()).Should(BeTrue())
+	})
+
+	It("should create the display sinks", func() {
+		sink, err := NewDisplaySink("terminal")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sink).Should(BeAssignableToTypeOf(&TerminalSink{}))
+
+		sink, err = NewDisplaySink(RecordSinkPrefix + filepath.Join(GinkgoT().TempDir(), "frames.jsonl"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sink).Should(BeAssignableToTypeOf(&Recorder{}))
+	})
+
+	DescribeTable("should reject a wrong display sink", func(spec string) {
+		_, err := NewDisplaySink(spec)
+		Expect(err).To(HaveOccurred())
+	},
+		Entry("unknown", "printer"),
+		Entry("no file name", "record:"),
+	)
+})
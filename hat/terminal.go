@@ -0,0 +1,280 @@
+package hat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"golang.org/x/term"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// ANSI escape sequences used by the terminal emulation
+const (
+	escClearScreen   = "\x1b[2J"
+	escHome          = "\x1b[H"
+	escSaveCursor    = "\x1b7"
+	escRestoreCursor = "\x1b8"
+	escHideCursor    = "\x1b[?25l"
+	escShowCursor    = "\x1b[?25h"
+	escResetColor    = "\x1b[0m"
+	escResetScroll   = "\x1b[r"
+	// keep the first 9 lines for the LED matrix; the logs are scrolling below it
+	escScrollBelowMatrix = "\x1b[10;r\x1b[10;1H"
+)
+
+const (
+	keyCtrlC = 0x03
+	keyEsc   = 0x1b
+	// keyMenu is a long press; the terminal has no gestures, so this is the way to the menu
+	keyMenu = 'm'
+)
+
+// Terminal emulates the Sense HAT in a terminal. The LED matrix is drawn as an 8X8 block of truecolor ANSI
+// cells, and the arrow keys and the Enter key are used as the joystick. The m key is a long press.
+type Terminal struct {
+	events   chan<- Event
+	screen   <-chan DisplayMessage
+	done     chan struct{}
+	keys     chan Event
+	in       io.Reader
+	out      io.Writer
+	oldState *term.State
+	blink    *blinker
+	last     DisplayMessage
+	// drawn is the frame the terminal shows
+	drawn string
+	*drawStatus
+}
+
+func NewTerminal(joystickEvents chan<- Event, screenEvents <-chan DisplayMessage) *Terminal {
+	return &Terminal{
+		events:     joystickEvents,
+		screen:     screenEvents,
+		done:       make(chan struct{}),
+		keys:       make(chan Event, 4),
+		in:         os.Stdin,
+		out:        os.Stdout,
+		blink:      newBlinker(),
+		drawStatus: &drawStatus{},
+	}
+}
+
+func (t *Terminal) Start() error {
+	t.init()
+	go t.readKeys()
+	go t.do()
+
+	return nil
+}
+
+func (t *Terminal) Stop() {
+	close(t.done)
+}
+
+func (t *Terminal) init() {
+	if f, ok := t.in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		oldState, err := term.MakeRaw(int(f.Fd()))
+		if err != nil {
+			log.Println("Can't set the terminal to raw mode;", err)
+		} else {
+			t.oldState = oldState
+			// in raw mode, new line does not return the carriage
+			log.SetOutput(crlfWriter{w: os.Stderr})
+		}
+	}
+
+	fmt.Fprint(t.out, escClearScreen+escHome+escHideCursor+escScrollBelowMatrix)
+}
+
+func (t *Terminal) do() {
+	defer t.gracefulShutDown()
+	defer t.blink.stop()
+
+	for {
+		select {
+		case event := <-t.keys:
+			t.events <- event
+			log.Println("Joystick Event:", event)
+
+		case screenChange := <-t.screen:
+			t.blink.reset(screenChange.blinking())
+			t.drawScreen(screenChange)
+
+		case <-t.blink.c:
+			t.blink.toggle()
+			t.drawScreen(t.last)
+
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *Terminal) readKeys() {
+	var decoder keyDecoder
+	buf := make([]byte, 16)
+
+	for {
+		n, err := t.in.Read(buf)
+		if err != nil {
+			return
+		}
+
+		events, interrupted := decoder.decode(buf[:n])
+		if interrupted {
+			// raw mode swallows Ctrl-C, so we need to send the signal by ourselves
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				_ = p.Signal(os.Interrupt)
+			}
+		}
+
+		for _, event := range events {
+			select {
+			case t.keys <- event:
+			case <-t.done:
+				return
+			}
+		}
+	}
+}
+
+// drawScreen draws the display message, unless the terminal already shows the same frame
+func (t *Terminal) drawScreen(screenChange DisplayMessage) {
+	t.last = screenChange
+	frame := renderTerminalFrame(screenChange, t.blink.on)
+	if frame == t.drawn {
+		return
+	}
+
+	_, err := fmt.Fprint(t.out, escSaveCursor+escHome+frame+escRestoreCursor)
+	if err != nil {
+		log.Println("error while printing to the terminal:", err)
+		t.drawn = ""
+	} else {
+		t.drawn = frame
+	}
+	t.set(err)
+}
+
+func (t *Terminal) gracefulShutDown() {
+	fmt.Fprint(t.out, escHome+escClearScreen+escResetScroll+escShowCursor)
+
+	if t.oldState != nil {
+		log.SetOutput(os.Stderr)
+		if f, ok := t.in.(*os.File); ok {
+			_ = term.Restore(int(f.Fd()), t.oldState)
+		}
+	}
+
+	// signal the controller we've done
+	close(t.events)
+}
+
+// renderTerminalFrame builds the ANSI representation of the display. Each LED is two characters wide, so it will look
+// square. The colors are first converted to the HAT colors, to show what the real LED matrix would display.
+func renderTerminalFrame(screenChange DisplayMessage, cursorOn bool) string {
+	buf := &bytes.Buffer{}
+	for y := 0; y < common.WindowSize; y++ {
+		for x := 0; x < common.WindowSize; x++ {
+			c := screenChange.pixelColor(x, y, cursorOn)
+			r, g, b := fromHatColor(screenChange.convert(c, x, y))
+			fmt.Fprintf(buf, "\x1b[48;2;%d;%d;%dm  ", r, g, b)
+		}
+		buf.WriteString(escResetColor + "\r\n")
+	}
+
+	return buf.String()
+}
+
+// TerminalSink draws the display messages in the terminal, like the terminal backend, but without reading the
+// keyboard. It's used to watch the Sense HAT display from an SSH session.
+type TerminalSink struct {
+	out io.Writer
+}
+
+func NewTerminalSink() *TerminalSink {
+	return &TerminalSink{out: os.Stdout}
+}
+
+func (s *TerminalSink) Display(msg DisplayMessage) error {
+	_, err := fmt.Fprint(s.out, escSaveCursor+escHome+renderTerminalFrame(msg, true)+escRestoreCursor)
+	return err
+}
+
+// fromHatColor expands the 16-bit HAT color back to 8 bits per channel, by repeating the MS bits in the LS bits
+func fromHatColor(c hatColor) (uint8, uint8, uint8) {
+	r := uint8((c >> 11) & 0x1F)
+	g := uint8((c >> 5) & 0x3F)
+	b := uint8(c & 0x1F)
+
+	return r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2
+}
+
+// keyDecoder translates the raw terminal input to joystick events. Escape sequences may be split between reads, so
+// the decoder keeps the incomplete sequence until the next read.
+type keyDecoder struct {
+	pending []byte
+}
+
+func (d *keyDecoder) decode(data []byte) ([]Event, bool) {
+	data = append(d.pending, data...)
+	d.pending = nil
+
+	var events []Event
+	interrupted := false
+
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\r', '\n', ' ':
+			events = append(events, Pressed)
+
+		case keyMenu:
+			events = append(events, LongPressed)
+
+		case keyCtrlC:
+			interrupted = true
+
+		case keyEsc:
+			// arrow keys are "ESC [ X" or, in application mode, "ESC O X"
+			if i+2 >= len(data) {
+				d.pending = append([]byte{}, data[i:]...)
+				return events, interrupted
+			}
+
+			if data[i+1] != '[' && data[i+1] != 'O' {
+				continue
+			}
+
+			switch data[i+2] {
+			case 'A':
+				events = append(events, MoveUp)
+			case 'B':
+				events = append(events, MoveDown)
+			case 'C':
+				events = append(events, MoveRight)
+			case 'D':
+				events = append(events, MoveLeft)
+			}
+			i += 2
+		}
+	}
+
+	return events, interrupted
+}
+
+// crlfWriter adds carriage return to each new line
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (w crlfWriter) Write(p []byte) (int, error) {
+	_, err := w.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n")))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
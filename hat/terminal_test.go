@@ -0,0 +1,176 @@
+package hat
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test the terminal emulation", func() {
+	Context("test keyDecoder", func() {
+		It("should decode the arrow keys", func() {
+			var d keyDecoder
+			events, interrupted := d.decode([]byte("\x1b[A\x1b[B\x1b[C\x1b[D"))
+			Expect(interrupted).Should(BeFalse())
+			Expect(events).Should(Equal([]Event{MoveUp, MoveDown, MoveRight, MoveLeft}))
+		})
+
+		It("should decode the arrow keys in application mode", func() {
+			var d keyDecoder
+			events, _ := d.decode([]byte("\x1bOA\x1bOD"))
+			Expect(events).Should(Equal([]Event{MoveUp, MoveLeft}))
+		})
+
+		It("should decode enter as press", func() {
+			var d keyDecoder
+			events, _ := d.decode([]byte("\r\n "))
+			Expect(events).Should(Equal([]Event{Pressed, Pressed, Pressed}))
+		})
+
+		It("should decode m as long press", func() {
+			var d keyDecoder
+			events, _ := d.decode([]byte("m\r"))
+			Expect(events).Should(Equal([]Event{LongPressed, Pressed}))
+		})
+
+		It("should keep split escape sequence for the next read", func() {
+			var d keyDecoder
+			events, _ := d.decode([]byte("\r\x1b["))
+			Expect(events).Should(Equal([]Event{Pressed}))
+
+			events, _ = d.decode([]byte("C"))
+			Expect(events).Should(Equal([]Event{MoveRight}))
+		})
+
+		It("should ignore unknown keys", func() {
+			var d keyDecoder
+			events, interrupted := d.decode([]byte("abc\x1b[5~"))
+			Expect(interrupted).Should(BeFalse())
+			Expect(events).Should(BeEmpty())
+		})
+
+		It("should detect Ctrl-C", func() {
+			var d keyDecoder
+			_, interrupted := d.decode([]byte{keyCtrlC})
+			Expect(interrupted).Should(BeTrue())
+		})
+	})
+
+	Context("test fromHatColor", func() {
+		It("should expand white", func() {
+			r, g, b := fromHatColor(0xFFFF)
+			Expect([]uint8{r, g, b}).Should(Equal([]uint8{0xFF, 0xFF, 0xFF}))
+		})
+
+		It("should expand black", func() {
+			r, g, b := fromHatColor(0)
+			Expect([]uint8{r, g, b}).Should(Equal([]uint8{0, 0, 0}))
+		})
+
+		It("should expand each channel", func() {
+			r, g, b := fromHatColor(toHatColor(0x804020))
+			Expect([]uint8{r, g, b}).Should(Equal([]uint8{0x84, 0x41, 0x21}))
+		})
+	})
+
+	Context("test renderTerminalFrame", func() {
+		It("should draw the matrix with the inverted cursor", func() {
+			msg := NewDisplayMessage(newTestScreen(0), 2, 1)
+			msg.Screen[0][0] = 0xFFFFFF
+
+			frame := renderTerminalFrame(msg, true)
+			lines := strings.Split(frame, "\r\n")
+			Expect(lines).Should(HaveLen(common.WindowSize + 1))
+
+			Expect(strings.Count(lines[0], "  ")).Should(Equal(common.WindowSize))
+			Expect(lines[0]).Should(HavePrefix("\x1b[48;2;255;255;255m  \x1b[48;2;0;0;0m  "))
+			Expect(lines[1]).Should(HavePrefix("\x1b[48;2;0;0;0m  \x1b[48;2;0;0;0m  \x1b[48;2;255;255;255m  "))
+			Expect(lines[1]).Should(HaveSuffix(escResetColor))
+		})
+
+		It("should hide the cursor when the blinking cursor is off", func() {
+			msg := NewDisplayMessage(newTestScreen(0), 2, 1)
+
+			lines := strings.Split(renderTerminalFrame(msg, false), "\r\n")
+			Expect(lines[1]).ShouldNot(ContainSubstring("\x1b[48;2;255;255;255m"))
+		})
+	})
+
+	Context("test the Terminal", func() {
+		It("should send the keys as joystick events and draw the display", func() {
+			je := make(chan Event, 1)
+			se := make(chan DisplayMessage, 1)
+
+			inReader, inWriter := io.Pipe()
+			out := &syncBuffer{}
+
+			t := NewTerminal(je, se)
+			t.in = inReader
+			t.out = out
+			t.Start()
+
+			_, err := inWriter.Write([]byte("\x1b[A"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Eventually(je).Should(Receive(Equal(MoveUp)))
+
+			se <- NewDisplayMessage(newTestScreen(0x123456), 0, 0)
+			Eventually(out.String).Should(ContainSubstring("\x1b[48;2;16;52;82m"))
+
+			t.Stop()
+			Eventually(je, time.Second).Should(BeClosed())
+		})
+
+		It("should not draw the same frame again", func() {
+			out := &syncBuffer{}
+			t := NewTerminal(make(chan Event), make(chan DisplayMessage))
+			t.out = out
+
+			msg := NewDisplayMessage(newTestScreen(0x123456), 0, 0)
+			t.drawScreen(msg)
+			drawn := out.String()
+			Expect(drawn).ShouldNot(BeEmpty())
+
+			t.drawScreen(msg)
+			Expect(out.String()).Should(Equal(drawn))
+
+			msg.CursorX = 1
+			t.drawScreen(msg)
+			Expect(out.String()).ShouldNot(Equal(drawn))
+		})
+	})
+})
+
+func newTestScreen(c common.Color) [][]common.Color {
+	mat := make([][]common.Color, common.WindowSize)
+	for y := range mat {
+		mat[y] = make([]common.Color, common.WindowSize)
+		for x := range mat[y] {
+			mat[y][x] = c
+		}
+	}
+	return mat
+}
+
+type syncBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
@@ -7,22 +7,62 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/nunnatsa/piHatDraw/controller"
+	"github.com/nunnatsa/piHatDraw/hat"
 	"github.com/nunnatsa/piHatDraw/notifier"
+	"github.com/nunnatsa/piHatDraw/state"
 	"github.com/nunnatsa/piHatDraw/webapp"
 )
 
 var (
 	canvasWidth, canvasHeight uint8
 	port                      uint16
+	hatName                   string
+	hatOptions                hat.Options
+	extraInputs               []hat.InputSelector
+	keymap                    controller.Keymap
+	displays                  []string
+	saveDir                   string
+	idleSettings              = controller.DefaultIdleSettings
+	historySettings           = state.DefaultHistorySettings
+	leader                    string
+	regionX, regionY          uint8
+	forwardJoystick           bool
 )
 
 func init() {
 	var width, height, prt uint
+	var input, extraInputList, keys, displayList string
+	var rotation int
+	var dimBrightness uint
+	var undoMemory int
+	var rgnX, rgnY uint
+	var flipH, flipV bool
 	flag.UintVar(&width, "width", 24, "Canvas width in pixels")
 	flag.UintVar(&height, "height", 24, "Canvas height in pixels")
 	flag.UintVar(&prt, "port", 8080, "The application port")
+	flag.StringVar(&hatName, "hat", hat.AutoName, fmt.Sprintf("The HAT backend; one of %s", strings.Join(hat.Names(), ", ")))
+	flag.StringVar(&input, "input", "", "The joystick input device; name:<device name>, handler:<event handler> or path:<device file>. The default is the Sense HAT joystick")
+	flag.StringVar(&extraInputList, "extra-input", "", "Comma separated additional joysticks or gamepads, each with its own cursor; in the -input format")
+	flag.IntVar(&rotation, "rotation", 0, "The Sense HAT rotation in degrees; one of 0, 90, 180 or 270")
+	flag.BoolVar(&flipH, "hflip", false, "Flip the Sense HAT display horizontally")
+	flag.BoolVar(&flipV, "vflip", false, "Flip the Sense HAT display vertically")
+	flag.BoolVar(&hatOptions.IMU, "imu", false, "Move the cursor by tilting the Sense HAT, and undo by shaking it")
+	flag.StringVar(&displayList, "display", "", "Comma separated additional displays; terminal, or record:<file name> to record the display frames")
+	flag.StringVar(&saveDir, "save-dir", ".", "The directory of the images that are saved from the HAT menu")
+	flag.DurationVar(&idleSettings.DimAfter, "dim-after", idleSettings.DimAfter, "Dim the display after this idle time; 0 to keep the display on")
+	flag.UintVar(&dimBrightness, "dim-brightness", uint(idleSettings.DimBrightness), "The percentage of the brightness, while the display is dimmed")
+	flag.DurationVar(&idleSettings.SleepAfter, "sleep-after", idleSettings.SleepAfter, "Blank the display, or start the screensaver, after this idle time; 0 to keep the display dimmed")
+	flag.BoolVar(&idleSettings.Screensaver, "screensaver", false, "Pan the display across the canvas while idle, instead of blanking it")
+	flag.IntVar(&historySettings.Depth, "undo-depth", historySettings.Depth, "The maximum number of undo steps")
+	flag.IntVar(&undoMemory, "undo-memory", historySettings.MaxBytes/1024, "The maximum memory of the undo history, in KiB; the oldest steps are dropped first")
+	flag.StringVar(&leader, "leader", "", "Follow the leader in this address (e.g. pi1:8080), and display a region of its canvas, as a piece of a video wall")
+	flag.UintVar(&rgnX, "region-x", 0, "The left column of the region of the leader's canvas that the follower displays")
+	flag.UintVar(&rgnY, "region-y", 0, "The top row of the region of the leader's canvas that the follower displays")
+	flag.BoolVar(&forwardJoystick, "forward-joystick", false, "Send the follower's joystick events to the leader")
+	flag.StringVar(&keys, "keymap", "", "Comma separated joystick gesture bindings, to override the default ones; e.g. LongPressed=cycleColor,DoublePressed=undo")
 
 	flag.Parse()
 
@@ -48,6 +88,56 @@ func init() {
 
 	port = uint16(prt)
 
+	selector, err := hat.ParseInputSelector(input)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	hatOptions.Input = selector
+
+	if extraInputList != "" {
+		for _, s := range strings.Split(extraInputList, ",") {
+			selector, err = hat.ParseInputSelector(s)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			extraInputs = append(extraInputs, selector)
+		}
+	}
+
+	hatOptions.Orientation = hat.Orientation{Rotation: rotation, FlipH: flipH, FlipV: flipV}
+	if err = hatOptions.Orientation.Validate(); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	if dimBrightness > 100 {
+		log.Fatal("ERROR: The dim brightness must be between 0 and 100")
+	}
+	idleSettings.DimBrightness = uint8(dimBrightness)
+
+	historySettings.MaxBytes = undoMemory * 1024
+	if err = historySettings.Validate(); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	keymap, err = controller.ParseKeymap(keys)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	if displayList != "" {
+		displays = strings.Split(displayList, ",")
+	}
+
+	if rgnX >= 40 || rgnY >= 40 {
+		log.Fatal("ERROR: The region must start inside the canvas; the maximum canvas size is 40X40 pixels")
+	}
+	regionX, regionY = uint8(rgnX), uint8(rgnY)
+
+	if leader != "" {
+		fmt.Printf("Following the leader in %s\n", leader)
+		return
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		log.Panic(err)
@@ -57,6 +147,11 @@ func init() {
 }
 
 func main() {
+	if leader != "" {
+		follow()
+		return
+	}
+
 	n := notifier.NewNotifier()
 	defer n.Close()
 
@@ -68,7 +163,19 @@ func main() {
 	portStr := fmt.Sprintf(":%d", port)
 	server := http.Server{Addr: portStr, Handler: webApplication.GetMux()}
 
-	control := controller.NewController(n, clientEvents, canvasWidth, canvasHeight)
+	control, err := controller.NewController(n, clientEvents, canvasWidth, canvasHeight, controller.Options{
+		HatName:     hatName,
+		Hat:         hatOptions,
+		ExtraInputs: extraInputs,
+		Keymap:      keymap,
+		Displays:    displays,
+		SaveDir:     saveDir,
+		Idle:        idleSettings,
+		History:     historySettings,
+	})
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
 	done := control.Start()
 
 	go func() {
@@ -83,3 +190,14 @@ func main() {
 	}
 	fmt.Println("\nGood Bye!")
 }
+
+// follow runs the application as a follower of another instance; there is no web application
+func follow() {
+	follower, err := controller.NewFollower(leader, regionX, regionY, forwardJoystick, hatName, hatOptions)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	<-follower.Start()
+	fmt.Println("\nGood Bye!")
+}
@@ -1,7 +1,11 @@
 package state
 
 import (
+	"container/list"
+	"fmt"
+
 	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
 )
 
 type Pixel struct {
@@ -17,35 +21,131 @@ type Change struct {
 	ToolName string        `json:"toolName,omitempty"`
 	Color    *common.Color `json:"color,omitempty"`
 
-	Pixels []Pixel `json:"pixels,omitempty"`
-}
+	DisplaySettings *hat.DisplaySettings `json:"displaySettings,omitempty"`
+	// Overview is set when entering or leaving the overview mode
+	Overview *bool `json:"overview,omitempty"`
+	// Zoom is the size of the LED block of each canvas pixel on the HAT display; the window is 8/zoom pixels wide
+	Zoom uint8 `json:"zoom,omitempty"`
+	// Cursors are the cursors of all the input devices, when there is more than one device
+	Cursors []DeviceCursor `json:"cursors,omitempty"`
+	// CanUndo and CanRedo are set when the undo history is changed
+	CanUndo *bool `json:"canUndo,omitempty"`
+	CanRedo *bool `json:"canRedo,omitempty"`
+	// Line is the pending line of the line tool; it's sent when the pending line is changed
+	Line *LinePreview `json:"line,omitempty"`
 
-type changeNode struct {
-	data *Change
-	next *changeNode
+	Pixels []Pixel `json:"pixels,omitempty"`
 }
 
+// changeStack is the list of the changes that revert the steps, from the newest to the oldest. It's a doubly linked
+// list, so dropping the oldest change is as fast as popping the newest one.
 type changeStack struct {
-	head *changeNode
+	changes list.List
 }
 
 func (s *changeStack) push(change *Change) {
-	s.head = &changeNode{
-		data: change,
-		next: s.head,
-	}
+	s.changes.PushFront(change)
 }
 
 func (s *changeStack) pop() *Change {
-	if s == nil || s.head == nil {
+	if s == nil {
+		return nil
+	}
+	return s.remove(s.changes.Front())
+}
+
+// dropOldest removes the bottom of the stack
+func (s *changeStack) dropOldest() *Change {
+	return s.remove(s.changes.Back())
+}
+
+func (s *changeStack) remove(e *list.Element) *Change {
+	if e == nil {
 		return nil
 	}
-	res := s.head
-	s.head = s.head.next
+	return s.changes.Remove(e).(*Change)
+}
+
+func (s *changeStack) len() int {
+	if s == nil {
+		return 0
+	}
+	return s.changes.Len()
+}
+
+// HistorySettings are the limits of the undo history. When a limit is reached, the oldest steps are dropped.
+type HistorySettings struct {
+	// Depth is the maximum number of undo steps
+	Depth int
+	// MaxBytes is the maximum memory of the undo and redo steps. The newest step is always kept, even if it's bigger.
+	MaxBytes int
+}
+
+var DefaultHistorySettings = HistorySettings{
+	Depth:    100,
+	MaxBytes: 1 << 20,
+}
+
+func (s HistorySettings) Validate() error {
+	if s.Depth < 1 {
+		return fmt.Errorf("the undo depth must be at least 1")
+	}
+
+	if s.MaxBytes < 1 {
+		return fmt.Errorf("the undo memory must be at least 1 byte")
+	}
+
+	return nil
+}
+
+// the approximate memory of a canvas color and of a pixel in a change
+const (
+	colorBytes = 4
+	pixelBytes = 8
+)
+
+func changeBytes(c *Change) int {
+	n := len(c.Pixels) * pixelBytes
+	for _, line := range c.Canvas {
+		n += len(line) * colorBytes
+	}
+	return n
+}
+
+// history is the undo and redo lists of a state. Each change in the lists reverts a step.
+type history struct {
+	undo     changeStack
+	redo     changeStack
+	settings HistorySettings
+	// bytes is the memory of the changes in both lists
+	bytes int
+}
+
+func newHistory(settings HistorySettings) *history {
+	return &history{settings: settings}
+}
+
+// push adds the change that reverts a new step to the undo list, and clears the redo list
+func (h *history) push(change *Change) {
+	for h.redo.len() > 0 {
+		h.pop(&h.redo)
+	}
 
-	return res.data
+	h.add(&h.undo, change)
+	for h.undo.len() > 1 && (h.undo.len() > h.settings.Depth || h.bytes > h.settings.MaxBytes) {
+		h.bytes -= changeBytes(h.undo.dropOldest())
+	}
 }
 
-var undoList = &changeStack{
-	head: nil,
+func (h *history) add(stack *changeStack, change *Change) {
+	stack.push(change)
+	h.bytes += changeBytes(change)
+}
+
+func (h *history) pop(stack *changeStack) *Change {
+	change := stack.pop()
+	if change != nil {
+		h.bytes -= changeBytes(change)
+	}
+	return change
 }
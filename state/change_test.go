@@ -3,6 +3,8 @@ package state
 import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
 )
 
 var _ = Describe("test change", func() {
@@ -58,17 +60,101 @@ var _ = Describe("test change", func() {
 
 		Expect(s.pop()).To(BeNil())
 	})
+
+	It("should drop the oldest change", func() {
+		s := changeStack{}
+		s.push(&Change{ToolName: "first"})
+		s.push(&Change{ToolName: "second"})
+
+		Expect(s.dropOldest().ToolName).Should(Equal("first"))
+		Expect(s.len()).Should(Equal(1))
+		Expect(s.dropOldest().ToolName).Should(Equal("second"))
+		Expect(s.dropOldest()).To(BeNil())
+	})
 })
 
-func (s *changeStack) len() int {
-	if s == nil {
-		return 0
+var _ = Describe("test history", func() {
+	pixelChange := func(color common.Color) *Change {
+		return &Change{Pixels: []Pixel{{X: 1, Y: 1, Color: color}}}
 	}
 
-	res := 0
-	for p := s.head; p != nil; p = p.next {
-		res++
-	}
+	It("should limit the number of steps", func() {
+		h := newHistory(HistorySettings{Depth: 2, MaxBytes: 1000})
+		h.push(pixelChange(1))
+		h.push(pixelChange(2))
+		h.push(pixelChange(3))
+
+		Expect(h.undo.len()).Should(Equal(2))
+		Expect(h.bytes).Should(Equal(2 * pixelBytes))
+		Expect(h.pop(&h.undo).Pixels[0].Color).Should(BeEquivalentTo(3))
+		Expect(h.pop(&h.undo).Pixels[0].Color).Should(BeEquivalentTo(2))
+		Expect(h.bytes).Should(BeZero())
+	})
+
+	It("should drop the oldest steps of a deep history", func() {
+		h := newHistory(HistorySettings{Depth: 1000, MaxBytes: 1 << 20})
+		for i := 0; i < 5000; i++ {
+			h.push(pixelChange(common.Color(i)))
+		}
+
+		Expect(h.undo.len()).Should(Equal(1000))
+		Expect(h.undo.dropOldest().Pixels[0].Color).Should(BeEquivalentTo(4000))
+		Expect(h.undo.pop().Pixels[0].Color).Should(BeEquivalentTo(4999))
+	})
 
-	return res
-}
+	It("should limit the memory, and keep the newest step", func() {
+		h := newHistory(HistorySettings{Depth: 100, MaxBytes: 3 * pixelBytes})
+		for i := 0; i < 5; i++ {
+			h.push(pixelChange(common.Color(i)))
+		}
+		Expect(h.undo.len()).Should(Equal(3))
+
+		h.push(&Change{Canvas: NewState(canvasWidth, canvasHeight).GetCanvasClone()})
+		Expect(h.undo.len()).Should(Equal(1))
+		Expect(h.bytes).Should(Equal(int(canvasWidth) * int(canvasHeight) * colorBytes))
+	})
+
+	It("should clear the redo list on a new step", func() {
+		h := newHistory(DefaultHistorySettings)
+		h.push(pixelChange(1))
+		h.add(&h.redo, h.pop(&h.undo))
+		Expect(h.redo.len()).Should(Equal(1))
+
+		h.push(pixelChange(2))
+		Expect(h.redo.len()).Should(BeZero())
+		Expect(h.bytes).Should(Equal(pixelBytes))
+	})
+
+	It("should not share the history between states", func() {
+		s1 := NewState(canvasWidth, canvasHeight)
+		s2 := NewState(canvasWidth, canvasHeight)
+
+		s1.Paint()
+		Expect(s2.Undo()).Should(BeNil())
+		Expect(s1.Undo()).ShouldNot(BeNil())
+	})
+
+	It("should undo and redo the reset", func() {
+		s := NewState(canvasWidth, canvasHeight)
+		s.Paint()
+		s.Reset()
+
+		change := s.Undo()
+		Expect(change.Canvas[s.cursor.Y][s.cursor.X]).Should(Equal(wightColor))
+		change = s.Redo()
+		Expect(change.Canvas[s.cursor.Y][s.cursor.X]).Should(BeEquivalentTo(0))
+		Expect(s.Redo()).Should(BeNil())
+	})
+
+	DescribeTable("should validate the settings", func(settings HistorySettings, valid bool) {
+		if valid {
+			Expect(settings.Validate()).To(Succeed())
+		} else {
+			Expect(settings.Validate()).ToNot(Succeed())
+		}
+	},
+		Entry("default", DefaultHistorySettings, true),
+		Entry("no steps", HistorySettings{Depth: 0, MaxBytes: 100}, false),
+		Entry("no memory", HistorySettings{Depth: 10, MaxBytes: 0}, false),
+	)
+})
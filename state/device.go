@@ -0,0 +1,110 @@
+package state
+
+import (
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// device is the drawing state of an input device
+type device struct {
+	cursor   cursor
+	window   window
+	toolName string
+	color    common.Color
+	anchor   *cursor
+}
+
+// DeviceCursor is the cursor of an input device, as sent to the web clients
+type DeviceCursor struct {
+	Device   int          `json:"device"`
+	X        uint8        `json:"x"`
+	Y        uint8        `json:"y"`
+	ToolName string       `json:"toolName"`
+	Color    common.Color `json:"color"`
+	Active   bool         `json:"active"`
+}
+
+// newDevice returns the initial state of the device: in the middle of the canvas, with the pen. Each device starts with
+// a different color of the palette; the first device starts with white.
+func (s State) newDevice(id int) device {
+	cr := cursor{X: s.canvasWidth / 2, Y: s.canvasHeight / 2}
+	return device{
+		cursor: cr,
+		window: window{
+			X: centerWindow(cr.X, s.windowSize(), s.canvasWidth),
+			Y: centerWindow(cr.Y, s.windowSize(), s.canvasHeight),
+		},
+		toolName: penName,
+		color:    palette[id%len(palette)],
+	}
+}
+
+// loadDevice sets the cursor, window, tool, color and pending line of the device as the current ones. The zoom level
+// may have been changed since the device was active, so the window is moved, if needed, to contain the cursor.
+func (s *State) loadDevice(d device) {
+	s.cursor = d.cursor
+	s.window = d.window
+	s.color = d.color
+	_, _ = s.SetTool(d.toolName)
+	s.anchor = d.anchor
+
+	size := s.windowSize()
+	if s.cursor.X < s.window.X || s.cursor.X >= s.window.X+size || s.window.X > s.canvasWidth-size {
+		s.window.X = centerWindow(s.cursor.X, size, s.canvasWidth)
+	}
+	if s.cursor.Y < s.window.Y || s.cursor.Y >= s.window.Y+size || s.window.Y > s.canvasHeight-size {
+		s.window.Y = centerWindow(s.cursor.Y, size, s.canvasHeight)
+	}
+}
+
+// SelectDevice makes the input device the active device; the moves, the painting, and the tool and color changes are
+// of the active device, and the display shows its window. A new device starts in the middle of the canvas. It returns
+// nil if the device is already active.
+func (s *State) SelectDevice(id int) *Change {
+	if id < 0 || id == s.active {
+		return nil
+	}
+
+	s.devices[s.active] = device{cursor: s.cursor, window: s.window, toolName: s.toolName, color: s.color, anchor: s.anchor}
+	for len(s.devices) <= id {
+		s.devices = append(s.devices, s.newDevice(len(s.devices)))
+	}
+
+	s.active = id
+	s.loadDevice(s.devices[id])
+
+	change := s.getPositionChange()
+	change.ToolName = s.toolName
+	color := s.color
+	change.Color = &color
+	change.Line = s.linePreview()
+	return change
+}
+
+// ActiveDevice returns the device number of the active input device
+func (s State) ActiveDevice() int {
+	return s.active
+}
+
+// deviceCursors returns the cursors of all the input devices, or nil if there is only one device
+func (s State) deviceCursors() []DeviceCursor {
+	if len(s.devices) < 2 {
+		return nil
+	}
+
+	cursors := make([]DeviceCursor, len(s.devices))
+	for i, d := range s.devices {
+		if i == s.active {
+			d = device{cursor: s.cursor, toolName: s.toolName, color: s.color}
+		}
+
+		cursors[i] = DeviceCursor{
+			Device:   i,
+			X:        d.cursor.X,
+			Y:        d.cursor.Y,
+			ToolName: d.toolName,
+			Color:    d.color,
+			Active:   i == s.active,
+		}
+	}
+	return cursors
+}
@@ -0,0 +1,81 @@
+package state
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test several input devices", func() {
+	var s *State
+
+	BeforeEach(func() {
+		s = NewState(canvasWidth, canvasHeight)
+	})
+
+	It("should not send the cursors with a single device", func() {
+		Expect(s.SelectDevice(0)).Should(BeNil())
+		Expect(s.GetFullChange().Cursors).Should(BeNil())
+		Expect(s.GoUp().Cursors).Should(BeNil())
+	})
+
+	It("should keep a cursor, a tool and a color for each device", func() {
+		s.GoUp()
+		_, _ = s.SetTool(eraserName)
+
+		By("adding a new device in the middle of the canvas, with its own color")
+		change := s.SelectDevice(1)
+		Expect(change).ShouldNot(BeNil())
+		Expect(*change.Cursor).Should(Equal(cursor{X: 20, Y: 12}))
+		Expect(change.ToolName).Should(Equal(penName))
+		Expect(*change.Color).Should(Equal(palette[1]))
+		Expect(s.ActiveDevice()).Should(Equal(1))
+
+		change = s.GoRight()
+		Expect(change.Cursors).Should(Equal([]DeviceCursor{
+			{Device: 0, X: 20, Y: 11, ToolName: eraserName, Color: wightColor},
+			{Device: 1, X: 21, Y: 12, ToolName: penName, Color: palette[1], Active: true},
+		}))
+
+		s.Paint()
+		Expect(s.canvas[12][21]).Should(Equal(palette[1]))
+
+		By("switching back to the first device")
+		change = s.SelectDevice(0)
+		Expect(*change.Cursor).Should(Equal(cursor{X: 20, Y: 11}))
+		Expect(change.ToolName).Should(Equal(eraserName))
+		Expect(*change.Color).Should(Equal(wightColor))
+
+		s.cursor = cursor{X: 21, Y: 12}
+		s.Paint()
+		Expect(s.canvas[12][21]).Should(Equal(common.Color(0)))
+	})
+
+	It("should fit the window of the device to the zoom level", func() {
+		s.SelectDevice(1)
+		s.cursor = cursor{X: canvasWidth - 1, Y: 0}
+		s.window = window{X: canvasWidth - 8, Y: 0}
+		s.SelectDevice(0)
+
+		_, err := s.SetZoom(4)
+		Expect(err).ToNot(HaveOccurred())
+
+		change := s.SelectDevice(1)
+		Expect(*change.Window).Should(Equal(window{X: canvasWidth - 2, Y: 0}))
+	})
+
+	It("should move all the cursors to the middle on reset", func() {
+		s.SelectDevice(2)
+		s.GoDown()
+		s.SelectDevice(0)
+		s.GoLeft()
+
+		change := s.Reset()
+		Expect(change.Cursors).Should(HaveLen(3))
+		for _, c := range change.Cursors {
+			Expect(c.X).Should(BeEquivalentTo(20))
+			Expect(c.Y).Should(BeEquivalentTo(12))
+		}
+	})
+})
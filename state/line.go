@@ -0,0 +1,146 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// LinePreview is the pending line of the line tool, from the anchor to the cursor. There are no pixels when there is
+// no pending line.
+type LinePreview struct {
+	Pixels []Pixel `json:"pixels"`
+}
+
+// line is the line tool. The first use sets the anchor at the cursor, and the second use draws the line from the
+// anchor to the cursor.
+func (s *State) line() *Change {
+	if s.anchor == nil {
+		anchor := s.cursor
+		s.anchor = &anchor
+		return &Change{Line: s.linePreview()}
+	}
+
+	from := *s.anchor
+	s.anchor = nil
+
+	change := s.drawLine(from, s.cursor, s.color)
+	if change == nil {
+		change = &Change{}
+	}
+	change.Line = &LinePreview{}
+	return change
+}
+
+// DrawLine draws a line between two canvas points, in the color, as one undo step. It's used by the web clients, that
+// don't need the anchor. It returns nil if nothing was changed.
+func (s *State) DrawLine(x0, y0, x1, y1 uint8, color common.Color) (*Change, error) {
+	if x0 >= s.canvasWidth || x1 >= s.canvasWidth || y0 >= s.canvasHeight || y1 >= s.canvasHeight {
+		return nil, fmt.Errorf("the line must be inside the %dX%d canvas", s.canvasWidth, s.canvasHeight)
+	}
+
+	return s.drawLine(cursor{X: x0, Y: y0}, cursor{X: x1, Y: y1}, color), nil
+}
+
+func (s *State) drawLine(from, to cursor, color common.Color) *Change {
+	var after, before []Pixel
+	for _, p := range linePoints(from, to) {
+		afterPx, beforePx := s.paintPixel(color, p.X, p.Y)
+		if afterPx != nil {
+			after = append(after, *afterPx)
+			before = append(before, *beforePx)
+		}
+	}
+
+	if len(after) == 0 {
+		return nil
+	}
+
+	s.history.push(&Change{Pixels: before})
+	return s.withHistory(&Change{Pixels: after})
+}
+
+// linePreview returns the pixels of the pending line, in the current color
+func (s State) linePreview() *LinePreview {
+	preview := &LinePreview{}
+	if s.anchor == nil {
+		return preview
+	}
+
+	for _, p := range linePoints(*s.anchor, s.cursor) {
+		preview.Pixels = append(preview.Pixels, Pixel{X: p.X, Y: p.Y, Color: s.color})
+	}
+	return preview
+}
+
+// drawLinePreview draws the pending line on the display window
+func (s State) drawLinePreview(screen [][]common.Color) {
+	if s.anchor == nil {
+		return
+	}
+
+	size := s.windowSize()
+	for _, p := range s.linePreview().Pixels {
+		if p.X < s.window.X || p.X >= s.window.X+size || p.Y < s.window.Y || p.Y >= s.window.Y+size {
+			continue
+		}
+
+		// each canvas pixel is displayed as a zoom X zoom block
+		x0, y0 := (p.X-s.window.X)*s.zoom, (p.Y-s.window.Y)*s.zoom
+		for y := y0; y < y0+s.zoom; y++ {
+			for x := x0; x < x0+s.zoom; x++ {
+				screen[y][x] = p.Color
+			}
+		}
+	}
+}
+
+// pendingLine returns the line preview for the changes of the cursor or the color, or nil if there is no pending line
+func (s State) pendingLine() *LinePreview {
+	if s.anchor == nil {
+		return nil
+	}
+	return s.linePreview()
+}
+
+// linePoints returns the points of the line from one point to another, using the Bresenham's line algorithm
+func linePoints(from, to cursor) []cursor {
+	x0, y0 := int(from.X), int(from.Y)
+	x1, y1 := int(to.X), int(to.Y)
+
+	dx := x1 - x0
+	if dx < 0 {
+		dx = -dx
+	}
+	dy := y0 - y1
+	if dy > 0 {
+		dy = -dy
+	}
+
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	var points []cursor
+	err := dx + dy
+	for {
+		points = append(points, cursor{X: uint8(x0), Y: uint8(y0)})
+		if x0 == x1 && y0 == y1 {
+			return points
+		}
+
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package state
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test the line tool", func() {
+	var s *State
+
+	BeforeEach(func() {
+		s = NewState(canvasWidth, canvasHeight)
+		_, err := s.SetTool(lineName)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	DescribeTable("should find the line points", func(from, to cursor, expected []cursor) {
+		Expect(linePoints(from, to)).Should(Equal(expected))
+	},
+		Entry("single point", cursor{X: 2, Y: 3}, cursor{X: 2, Y: 3}, []cursor{{X: 2, Y: 3}}),
+		Entry("horizontal", cursor{X: 3, Y: 1}, cursor{X: 1, Y: 1}, []cursor{{X: 3, Y: 1}, {X: 2, Y: 1}, {X: 1, Y: 1}}),
+		Entry("diagonal", cursor{X: 0, Y: 2}, cursor{X: 2, Y: 0}, []cursor{{X: 0, Y: 2}, {X: 1, Y: 1}, {X: 2, Y: 0}}),
+		Entry("shallow", cursor{X: 0, Y: 0}, cursor{X: 5, Y: 2}, []cursor{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 1}, {X: 3, Y: 1}, {X: 4, Y: 2}, {X: 5, Y: 2}}),
+	)
+
+	It("should set the anchor, preview the line, and draw it", func() {
+		change := s.Paint()
+		Expect(change.Line.Pixels).Should(Equal([]Pixel{{X: 20, Y: 12, Color: wightColor}}))
+		Expect(change.Pixels).Should(BeEmpty())
+
+		s.GoRight()
+		change = s.GoRight()
+		Expect(change.Line.Pixels).Should(HaveLen(3))
+
+		By("showing the preview on the display")
+		msg := s.CreateDisplayMessage()
+		Expect(msg.Screen[4][4:7]).Should(Equal([]common.Color{wightColor, wightColor, wightColor}))
+
+		change = s.Paint()
+		Expect(change.Pixels).Should(HaveLen(3))
+		Expect(change.Line.Pixels).Should(BeEmpty())
+		Expect(s.canvas[12][21]).Should(Equal(wightColor))
+		Expect(s.GoRight().Line).Should(BeNil())
+
+		By("undoing the line as one step")
+		Expect(s.Undo().Pixels).Should(HaveLen(3))
+		Expect(s.canvas[12][21]).Should(BeEquivalentTo(0))
+		Expect(s.Undo()).Should(BeNil())
+	})
+
+	It("should cancel the pending line when changing the tool", func() {
+		s.Paint()
+		change, err := s.SetTool(penName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(change.Line.Pixels).Should(BeEmpty())
+		Expect(s.anchor).Should(BeNil())
+	})
+
+	It("should draw a line with explicit end points", func() {
+		change, err := s.DrawLine(0, 0, 3, 3, 0x00FF00)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(change.Pixels).Should(HaveLen(4))
+		Expect(s.canvas[3][3]).Should(BeEquivalentTo(0x00FF00))
+
+		change, err = s.DrawLine(0, 0, 3, 3, 0x00FF00)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(change).Should(BeNil())
+
+		_, err = s.DrawLine(0, 0, canvasWidth, 3, 0x00FF00)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should keep the pending line of each device", func() {
+		s.Paint()
+		change := s.SelectDevice(1)
+		Expect(change.Line.Pixels).Should(BeEmpty())
+
+		change = s.SelectDevice(0)
+		Expect(change.Line.Pixels).Should(HaveLen(1))
+	})
+})
@@ -0,0 +1,162 @@
+package state
+
+import (
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+// overview is the state of the overview mode, where the whole canvas is downsampled to the HAT display
+type overview struct {
+	// selection is the overview cell the window will jump to
+	selection cursor
+}
+
+// InOverview returns true if the state is in the overview mode
+func (s State) InOverview() bool {
+	return s.overview != nil
+}
+
+// ToggleOverview enters the overview mode, or leaves it without moving the window
+func (s *State) ToggleOverview() *Change {
+	if s.overview != nil {
+		s.overview = nil
+	} else {
+		s.overview = &overview{
+			selection: cursor{
+				X: uint8(uint16(s.cursor.X) * common.WindowSize / uint16(s.canvasWidth)),
+				Y: uint8(uint16(s.cursor.Y) * common.WindowSize / uint16(s.canvasHeight)),
+			},
+		}
+	}
+
+	return s.getOverviewChange()
+}
+
+// MoveOverviewSelection moves the overview selection by dx, dy cells
+func (s *State) MoveOverviewSelection(dx, dy int) *Change {
+	if s.overview == nil {
+		return nil
+	}
+
+	x := int(s.overview.selection.X) + dx
+	y := int(s.overview.selection.Y) + dy
+	if x < 0 || x >= common.WindowSize || y < 0 || y >= common.WindowSize {
+		return nil
+	}
+
+	s.overview.selection = cursor{X: uint8(x), Y: uint8(y)}
+	return s.getOverviewChange()
+}
+
+// SelectOverview moves the cursor to the middle of the selected overview cell, centers the window around it, and
+// leaves the overview mode
+func (s *State) SelectOverview() *Change {
+	if s.overview == nil {
+		return nil
+	}
+
+	x0, x1 := overviewCellRange(s.overview.selection.X, s.canvasWidth)
+	y0, y1 := overviewCellRange(s.overview.selection.Y, s.canvasHeight)
+	s.cursor = cursor{X: (x0 + x1) / 2, Y: (y0 + y1) / 2}
+	s.window = window{
+		X: centerWindow(s.cursor.X, s.windowSize(), s.canvasWidth),
+		Y: centerWindow(s.cursor.Y, s.windowSize(), s.canvasHeight),
+	}
+	s.overview = nil
+
+	change := s.getPositionChange()
+	change.Overview = new(bool)
+	return change
+}
+
+func (s State) getOverviewChange() *Change {
+	inOverview := s.overview != nil
+	return &Change{Overview: &inOverview}
+}
+
+// overviewCellRange returns the canvas range [start, end) of the overview cell
+func overviewCellRange(cell, canvasSize uint8) (uint8, uint8) {
+	start := uint16(cell) * uint16(canvasSize) / common.WindowSize
+	end := (uint16(cell) + 1) * uint16(canvasSize) / common.WindowSize
+	return uint8(start), uint8(end)
+}
+
+// the color of the empty overview cells of the window, so the window is visible on an empty canvas
+const windowMarkColor = common.Color(0x303030)
+
+// createOverviewDisplayMessage downsamples the canvas to the display. The cells outside the window are dimmed, and the
+// empty cells of the window are marked, to show where the window is. The cursor is the overview selection.
+func (s State) createOverviewDisplayMessage() hat.DisplayMessage {
+	downsample := averageColor
+	if s.display.Downsample == hat.DownsampleMajority {
+		downsample = majorityColor
+	}
+
+	c := make([][]common.Color, common.WindowSize)
+	for y := uint8(0); y < common.WindowSize; y++ {
+		c[y] = make([]common.Color, common.WindowSize)
+		y0, y1 := overviewCellRange(y, s.canvasHeight)
+		for x := uint8(0); x < common.WindowSize; x++ {
+			x0, x1 := overviewCellRange(x, s.canvasWidth)
+
+			var colors []common.Color
+			inWindow := false
+			for cy := y0; cy < y1; cy++ {
+				colors = append(colors, s.canvas[cy][x0:x1]...)
+				for cx := x0; cx < x1; cx++ {
+					inWindow = inWindow || s.inWindow(cx, cy)
+				}
+			}
+
+			cl := downsample(colors)
+			if !inWindow {
+				cl = halfColor(cl)
+			} else if cl == backgroundColor {
+				cl = windowMarkColor
+			}
+			c[y][x] = cl
+		}
+	}
+
+	msg := hat.NewDisplayMessage(c, s.overview.selection.X, s.overview.selection.Y)
+	display := s.display
+	msg.Settings = &display
+
+	return msg
+}
+
+func (s State) inWindow(x, y uint8) bool {
+	size := s.windowSize()
+	return x >= s.window.X && x < s.window.X+size && y >= s.window.Y && y < s.window.Y+size
+}
+
+// averageColor returns the average of each channel of the colors
+func averageColor(colors []common.Color) common.Color {
+	var r, g, b uint32
+	for _, cl := range colors {
+		r += uint32(cl>>16) & 0xFF
+		g += uint32(cl>>8) & 0xFF
+		b += uint32(cl) & 0xFF
+	}
+
+	n := uint32(len(colors))
+	return common.Color((r/n)<<16 | (g/n)<<8 | b/n)
+}
+
+// majorityColor returns the most common color. On a tie, the color that reached the count first wins.
+func majorityColor(colors []common.Color) common.Color {
+	counts := make(map[common.Color]int)
+	var majority common.Color
+	for _, cl := range colors {
+		counts[cl]++
+		if counts[cl] > counts[majority] {
+			majority = cl
+		}
+	}
+
+	return majority
+}
+
+func halfColor(c common.Color) common.Color {
+	return (c >> 1) & 0x7F7F7F
+}
@@ -0,0 +1,130 @@
+package state
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+)
+
+var _ = Describe("test overview", func() {
+	var s *State
+
+	BeforeEach(func() {
+		s = NewState(canvasWidth, canvasHeight)
+	})
+
+	It("should toggle the overview mode", func() {
+		Expect(s.InOverview()).Should(BeFalse())
+
+		change := s.ToggleOverview()
+		Expect(*change.Overview).Should(BeTrue())
+		Expect(s.InOverview()).Should(BeTrue())
+
+		change = s.ToggleOverview()
+		Expect(*change.Overview).Should(BeFalse())
+		Expect(s.InOverview()).Should(BeFalse())
+		Expect(s.window).Should(Equal(window{X: 16, Y: 8}))
+	})
+
+	It("should select the cell of the cursor", func() {
+		s.ToggleOverview()
+		msg := s.CreateDisplayMessage()
+		Expect(msg.CursorX).Should(BeEquivalentTo(4))
+		Expect(msg.CursorY).Should(BeEquivalentTo(4))
+
+		s.cursor = cursor{X: canvasWidth - 1, Y: canvasHeight - 1}
+		s.ToggleOverview()
+		s.ToggleOverview()
+		msg = s.CreateDisplayMessage()
+		Expect(msg.CursorX).Should(BeEquivalentTo(7))
+		Expect(msg.CursorY).Should(BeEquivalentTo(7))
+	})
+
+	It("should downsample the canvas and mark the window", func() {
+		// the overview cells are 5x3 canvas pixels
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 5; x++ {
+				s.canvas[y][x] = 0xFF0000
+			}
+		}
+		s.canvas[9][20] = 0x0000F0
+
+		s.ToggleOverview()
+		msg := s.CreateDisplayMessage()
+		Expect(msg.Screen).Should(HaveLen(common.WindowSize))
+		Expect(msg.Screen[0]).Should(HaveLen(common.WindowSize))
+
+		Expect(msg.Screen[0][0]).Should(Equal(common.Color(0x7F0000)), "outside the window; dimmed")
+		Expect(msg.Screen[0][1]).Should(Equal(common.Color(0)))
+		Expect(msg.Screen[3][4]).Should(Equal(common.Color(0x000010)), "the window; average")
+		Expect(msg.Screen[3][3]).Should(Equal(windowMarkColor), "the window; empty")
+		Expect(msg.Screen[2][3]).Should(Equal(windowMarkColor), "partly in the window")
+		Expect(msg.Screen[7][7]).Should(Equal(common.Color(0)))
+	})
+
+	It("should downsample to the majority color", func() {
+		settings := hat.NewDisplaySettings()
+		settings.Downsample = hat.DownsampleMajority
+		_, err := s.SetDisplaySettings(settings)
+		Expect(err).ToNot(HaveOccurred())
+
+		s.canvas[9][20] = 0x0000F0
+		s.canvas[9][21] = 0x0000F0
+		s.canvas[10][20] = 0x0000F0
+		s.canvas[10][21] = 0x0000F0
+		s.canvas[11][20] = 0x0000F0
+		s.canvas[11][21] = 0x0000F0
+		s.canvas[11][22] = 0x0000F0
+		s.canvas[11][23] = 0x0000F0
+
+		s.ToggleOverview()
+		Expect(s.CreateDisplayMessage().Screen[3][4]).Should(Equal(common.Color(0x0000F0)))
+	})
+
+	It("should move the selection inside the overview", func() {
+		Expect(s.MoveOverviewSelection(1, 0)).Should(BeNil())
+
+		s.ToggleOverview()
+		Expect(s.MoveOverviewSelection(1, 0)).ShouldNot(BeNil())
+		Expect(s.overview.selection).Should(Equal(cursor{X: 5, Y: 4}))
+
+		s.overview.selection = cursor{X: 0, Y: 7}
+		Expect(s.MoveOverviewSelection(-1, 0)).Should(BeNil())
+		Expect(s.MoveOverviewSelection(0, 1)).Should(BeNil())
+		Expect(s.overview.selection).Should(Equal(cursor{X: 0, Y: 7}))
+	})
+
+	DescribeTable("should jump to the selected cell", func(selection cursor, expectedCursor cursor, expectedWindow window) {
+		Expect(s.SelectOverview()).Should(BeNil())
+
+		s.ToggleOverview()
+		s.overview.selection = selection
+
+		change := s.SelectOverview()
+		Expect(change).ShouldNot(BeNil())
+		Expect(*change.Overview).Should(BeFalse())
+		Expect(*change.Cursor).Should(Equal(expectedCursor))
+		Expect(*change.Window).Should(Equal(expectedWindow))
+		Expect(s.InOverview()).Should(BeFalse())
+
+		msg := s.CreateDisplayMessage()
+		Expect(msg.WindowX).Should(Equal(expectedWindow.X))
+		Expect(msg.WindowY).Should(Equal(expectedWindow.Y))
+	},
+		Entry("top left", cursor{X: 0, Y: 0}, cursor{X: 2, Y: 1}, window{X: 0, Y: 0}),
+		Entry("middle", cursor{X: 3, Y: 4}, cursor{X: 17, Y: 13}, window{X: 13, Y: 9}),
+		Entry("bottom right", cursor{X: 7, Y: 7}, cursor{X: 37, Y: 22}, window{X: 32, Y: 16}),
+	)
+
+	It("should downsample by average", func() {
+		Expect(averageColor([]common.Color{0xFF0000, 0x0000FF, 0x00FF00, 0})).Should(Equal(common.Color(0x3F3F3F)))
+	})
+
+	It("should downsample by majority, and prefer the color that reached the count first on a tie", func() {
+		Expect(majorityColor([]common.Color{0xFF0000, 0x0000FF, 0x0000FF})).Should(Equal(common.Color(0x0000FF)))
+		Expect(majorityColor([]common.Color{0xFF0000, 0x0000FF, 0x0000FF, 0xFF0000})).Should(Equal(common.Color(0x0000FF)))
+		Expect(majorityColor([]common.Color{0, 0x0000FF})).Should(Equal(common.Color(0)))
+	})
+})
@@ -0,0 +1,55 @@
+package state
+
+import "github.com/nunnatsa/piHatDraw/common"
+
+// Pan moves the window by dx, dy pixels, without moving the cursor. The window is kept inside the canvas. It returns
+// nil if the window was not moved.
+func (s *State) Pan(dx, dy int) *Change {
+	return s.PanTo(int(s.window.X)+dx, int(s.window.Y)+dy)
+}
+
+// PanTo moves the window to x, y, without moving the cursor. The window is kept inside the canvas. It returns nil if
+// the window was not moved.
+func (s *State) PanTo(x, y int) *Change {
+	size := int(s.windowSize())
+	win := window{
+		X: uint8(common.ClampInt(x, 0, int(s.canvasWidth)-size)),
+		Y: uint8(common.ClampInt(y, 0, int(s.canvasHeight)-size)),
+	}
+
+	if win == s.window {
+		return nil
+	}
+
+	s.window = win
+	return s.getPositionChange()
+}
+
+// SnapToCursor centers the window around the cursor. It returns nil if the window was not moved.
+func (s *State) SnapToCursor() *Change {
+	size := s.windowSize()
+	return s.PanTo(int(centerWindow(s.cursor.X, size, s.canvasWidth)), int(centerWindow(s.cursor.Y, size, s.canvasHeight)))
+}
+
+// cursorInWindow returns true if the cursor is inside the window; it's not, after panning away
+func (s State) cursorInWindow() bool {
+	size := s.windowSize()
+	return s.cursor.X >= s.window.X && s.cursor.X < s.window.X+size &&
+		s.cursor.Y >= s.window.Y && s.cursor.Y < s.window.Y+size
+}
+
+// followCursor scrolls the window the minimal distance that brings the cursor into it
+func (s *State) followCursor() {
+	size := s.windowSize()
+	if s.cursor.X < s.window.X {
+		s.window.X = s.cursor.X
+	} else if s.cursor.X >= s.window.X+size {
+		s.window.X = s.cursor.X - size + 1
+	}
+
+	if s.cursor.Y < s.window.Y {
+		s.window.Y = s.cursor.Y
+	} else if s.cursor.Y >= s.window.Y+size {
+		s.window.Y = s.cursor.Y - size + 1
+	}
+}
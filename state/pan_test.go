@@ -0,0 +1,60 @@
+package state
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("test panning", func() {
+	var s *State
+
+	BeforeEach(func() {
+		s = NewState(canvasWidth, canvasHeight)
+	})
+
+	It("should move the window without moving the cursor", func() {
+		change := s.Pan(-3, 2)
+		Expect(change).ShouldNot(BeNil())
+		Expect(*change.Window).Should(Equal(window{X: 13, Y: 10}))
+		Expect(*change.Cursor).Should(Equal(cursor{X: 20, Y: 12}))
+	})
+
+	It("should keep the window inside the canvas", func() {
+		change := s.PanTo(-5, 100)
+		Expect(*change.Window).Should(Equal(window{X: 0, Y: canvasHeight - 8}))
+
+		Expect(s.Pan(-1, 1)).Should(BeNil())
+	})
+
+	It("should hide the cursor when it's outside the window", func() {
+		Expect(s.CreateDisplayMessage().NoCursor).Should(BeFalse())
+
+		s.PanTo(0, 0)
+		msg := s.CreateDisplayMessage()
+		Expect(msg.NoCursor).Should(BeTrue())
+		Expect(msg.WindowX).Should(BeEquivalentTo(0))
+	})
+
+	It("should snap back to the cursor", func() {
+		s.PanTo(0, 0)
+		change := s.SnapToCursor()
+		Expect(*change.Window).Should(Equal(window{X: 16, Y: 8}))
+
+		Expect(s.SnapToCursor()).Should(BeNil())
+	})
+
+	It("should bring the cursor into the window when it moves", func() {
+		s.PanTo(0, 0)
+		change := s.GoRight()
+		Expect(*change.Window).Should(Equal(window{X: 14, Y: 5}))
+		Expect(s.CreateDisplayMessage().NoCursor).Should(BeFalse())
+	})
+
+	It("should keep the cursor inside the canvas when paging", func() {
+		s.PanTo(0, 0)
+		s.cursor = cursor{X: canvasWidth - 1, Y: 12}
+		change := s.PageRight()
+		Expect(*change.Window).Should(Equal(window{X: 8, Y: 0}))
+		Expect(*change.Cursor).Should(Equal(cursor{X: canvasWidth - 1, Y: 12}))
+	})
+})
@@ -12,14 +12,35 @@ const (
 	penName    = "pen"
 	eraserName = "eraser"
 	bucketName = "bucket"
+	lineName   = "line"
 )
 
+// the order of the tools, when cycling them
+var toolNames = []string{penName, eraserName, bucketName, lineName}
+
 const (
 	wightColor      = common.Color(0xFFFFFF)
 	blackColor      = common.Color(0)
 	backgroundColor = blackColor
 )
 
+// the colors to cycle, when changing the color from the joystick
+var palette = []common.Color{
+	wightColor,
+	0xFF0000, // red
+	0xFF8000, // orange
+	0xFFFF00, // yellow
+	0x00FF00, // green
+	0x00FFFF, // cyan
+	0x0000FF, // blue
+	0xFF00FF, // magenta
+}
+
+// Palette returns the colors of the color cycle
+func Palette() []common.Color {
+	return append([]common.Color{}, palette...)
+}
+
 type Canvas [][]common.Color
 
 func (c Canvas) Clone() Canvas {
@@ -57,12 +78,31 @@ type State struct {
 	toolName     string
 	tool         tool
 	color        common.Color
+	display      hat.DisplaySettings
+	overview     *overview
+	zoom         uint8
+	// devices are the input devices, by their device number. The cursor, window, tool and color of the active device
+	// are in the state fields, and are saved to its entry when another device becomes active.
+	devices []device
+	active  int
+	history *history
+	// anchor is the start of the pending line of the line tool, or nil if there is no pending line
+	anchor *cursor
 }
 
 func NewState(canvasWidth, canvasHeight uint8) *State {
+	return NewStateWithHistory(canvasWidth, canvasHeight, DefaultHistorySettings)
+}
+
+// NewStateWithHistory creates a state with the undo history limits
+func NewStateWithHistory(canvasWidth, canvasHeight uint8, history HistorySettings) *State {
 	s := &State{
 		canvasWidth:  canvasWidth,
 		canvasHeight: canvasHeight,
+		display:      hat.NewDisplaySettings(),
+		zoom:         1,
+		devices:      make([]device, 1),
+		history:      newHistory(history),
 	}
 
 	_ = s.Reset()
@@ -80,7 +120,7 @@ func (s *State) Reset() *Change {
 			Canvas: s.canvas.Clone(),
 		}
 
-		undoList.push(chng)
+		s.history.push(chng)
 	}
 
 	c := make([][]common.Color, s.canvasHeight)
@@ -88,15 +128,11 @@ func (s *State) Reset() *Change {
 		c[y] = make([]common.Color, s.canvasWidth)
 	}
 
-	cr := cursor{X: s.canvasWidth / 2, Y: s.canvasHeight / 2}
-	halfWindow := uint8(common.WindowSize / 2)
-	win := window{X: cr.X - halfWindow, Y: cr.Y - halfWindow}
-
 	s.canvas = c
-	s.cursor = cr
-	s.window = win
-	s.color = wightColor
-	_, _ = s.SetTool(penName)
+	for i := range s.devices {
+		s.devices[i] = s.newDevice(i)
+	}
+	s.loadDevice(s.devices[s.active])
 
 	return s.GetFullChange()
 }
@@ -104,9 +140,7 @@ func (s *State) Reset() *Change {
 func (s *State) GoUp() *Change {
 	if s.cursor.Y > 0 {
 		s.cursor.Y--
-		if s.cursor.Y < s.window.Y {
-			s.window.Y = s.cursor.Y
-		}
+		s.followCursor()
 		return s.getPositionChange()
 	}
 	return nil
@@ -115,9 +149,7 @@ func (s *State) GoUp() *Change {
 func (s *State) GoLeft() *Change {
 	if s.cursor.X > 0 {
 		s.cursor.X--
-		if s.cursor.X < s.window.X {
-			s.window.X = s.cursor.X
-		}
+		s.followCursor()
 		return s.getPositionChange()
 	}
 	return nil
@@ -126,9 +158,7 @@ func (s *State) GoLeft() *Change {
 func (s *State) GoDown() *Change {
 	if s.cursor.Y < s.canvasHeight-1 {
 		s.cursor.Y++
-		if s.cursor.Y > s.window.Y+common.WindowSize-1 {
-			s.window.Y++
-		}
+		s.followCursor()
 		return s.getPositionChange()
 	}
 
@@ -138,15 +168,58 @@ func (s *State) GoDown() *Change {
 func (s *State) GoRight() *Change {
 	if s.cursor.X < s.canvasWidth-1 {
 		s.cursor.X++
-		if s.cursor.X > s.window.X+common.WindowSize-1 {
-			s.window.X++
-		}
+		s.followCursor()
 		return s.getPositionChange()
 	}
 
 	return nil
 }
 
+// PageUp moves the window, and the cursor with it, by a full window up
+func (s *State) PageUp() *Change {
+	delta := minUint8(s.window.Y, s.windowSize())
+	return s.page(0, -int(delta))
+}
+
+// PageDown moves the window, and the cursor with it, by a full window down
+func (s *State) PageDown() *Change {
+	delta := minUint8(s.canvasHeight-s.windowSize()-s.window.Y, s.windowSize())
+	return s.page(0, int(delta))
+}
+
+// PageLeft moves the window, and the cursor with it, by a full window left
+func (s *State) PageLeft() *Change {
+	delta := minUint8(s.window.X, s.windowSize())
+	return s.page(-int(delta), 0)
+}
+
+// PageRight moves the window, and the cursor with it, by a full window right
+func (s *State) PageRight() *Change {
+	delta := minUint8(s.canvasWidth-s.windowSize()-s.window.X, s.windowSize())
+	return s.page(int(delta), 0)
+}
+
+func minUint8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (s *State) page(dx, dy int) *Change {
+	if dx == 0 && dy == 0 {
+		return nil
+	}
+
+	s.window.X = uint8(int(s.window.X) + dx)
+	s.window.Y = uint8(int(s.window.Y) + dy)
+	// the cursor may be outside the window, after panning
+	s.cursor.X = uint8(common.ClampInt(int(s.cursor.X)+dx, 0, int(s.canvasWidth)-1))
+	s.cursor.Y = uint8(common.ClampInt(int(s.cursor.Y)+dy, 0, int(s.canvasHeight)-1))
+
+	return s.getPositionChange()
+}
+
 func (s *State) Paint() *Change {
 	return s.tool()
 }
@@ -161,12 +234,12 @@ func (s *State) setSinglePixelTool(color common.Color) *Change {
 		change := &Change{
 			Pixels: []Pixel{*before},
 		}
-		undoList.push(change)
+		s.history.push(change)
 	}
 
-	return &Change{
+	return s.withHistory(&Change{
 		Pixels: []Pixel{*after},
-	}
+	})
 }
 
 func (s *State) pen() *Change {
@@ -232,11 +305,11 @@ func (s *State) bucket() *Change {
 			Pixels: before,
 		}
 
-		undoList.push(undoChange)
+		s.history.push(undoChange)
 
-		return &Change{
+		return s.withHistory(&Change{
 			Pixels: after,
-		}
+		})
 	}
 
 	return nil
@@ -268,26 +341,157 @@ func (s *State) paintPixel(color common.Color, x, y uint8) (*Pixel, *Pixel) {
 	return nil, nil
 }
 
+// PaintRegion paints the region that starts at x, y, with the colors of region; region[y][x] is the color of the
+// canvas pixel x, y, relative to the region. It's used by the automatic drawing, like the sensor plot, so the pixels
+// are not added to the undo list. The pixels outside the canvas are ignored. It returns nil if nothing was changed.
+func (s *State) PaintRegion(x, y uint8, region [][]common.Color) *Change {
+	var pixels []Pixel
+	for ry, line := range region {
+		cy := int(y) + ry
+		if cy >= int(s.canvasHeight) {
+			break
+		}
+
+		for rx, color := range line {
+			cx := int(x) + rx
+			if cx >= int(s.canvasWidth) {
+				break
+			}
+
+			if s.canvas[cy][cx] != color {
+				s.canvas[cy][cx] = color
+				pixels = append(pixels, Pixel{X: uint8(cx), Y: uint8(cy), Color: color})
+			}
+		}
+	}
+
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	return &Change{Pixels: pixels}
+}
+
 func (s State) CreateDisplayMessage() hat.DisplayMessage {
+	if s.overview != nil {
+		return s.createOverviewDisplayMessage()
+	}
+
+	// each canvas pixel is displayed as a zoom X zoom block
 	c := make([][]common.Color, common.WindowSize)
 	for y := uint8(0); y < common.WindowSize; y++ {
-		c[y] = make([]common.Color, 0, common.WindowSize)
-		c[y] = append(c[y], s.canvas[s.window.Y+y][s.window.X:s.window.X+common.WindowSize]...)
+		c[y] = make([]common.Color, common.WindowSize)
+		for x := uint8(0); x < common.WindowSize; x++ {
+			c[y][x] = s.canvas[s.window.Y+y/s.zoom][s.window.X+x/s.zoom]
+		}
+	}
+
+	s.drawLinePreview(c)
+
+	var msg hat.DisplayMessage
+	if s.cursorInWindow() {
+		msg = hat.NewDisplayMessage(c, (s.cursor.X-s.window.X)*s.zoom, (s.cursor.Y-s.window.Y)*s.zoom)
+	} else {
+		// the window was panned away from the cursor
+		msg = hat.NewDisplayMessage(c, 0, 0)
+		msg.NoCursor = true
 	}
+	msg.Zoom = s.zoom
+	msg.WindowX = s.window.X
+	msg.WindowY = s.window.Y
+	display := s.display
+	msg.Settings = &display
 
-	return hat.NewDisplayMessage(c, s.cursor.X-s.window.X, s.cursor.Y-s.window.Y)
+	return msg
+}
+
+// CreateWindowMessage creates a display message of the canvas window at x, y, without the cursor, regardless of the
+// current window and zoom. The window is kept inside the canvas.
+func (s State) CreateWindowMessage(x, y uint8) hat.DisplayMessage {
+	x = minUint8(x, s.canvasWidth-common.WindowSize)
+	y = minUint8(y, s.canvasHeight-common.WindowSize)
+
+	c := make([][]common.Color, common.WindowSize)
+	for dy := uint8(0); dy < common.WindowSize; dy++ {
+		c[dy] = append([]common.Color{}, s.canvas[y+dy][x:x+common.WindowSize]...)
+	}
+
+	msg := hat.NewDisplayMessage(c, 0, 0)
+	msg.NoCursor = true
+	msg.WindowX = x
+	msg.WindowY = y
+	display := s.display
+	msg.Settings = &display
+
+	return msg
+}
+
+func (s State) GetCanvasSize() (uint8, uint8) {
+	return s.canvasWidth, s.canvasHeight
 }
 
 func (s *State) SetColor(cl common.Color) *Change {
 	if s.color != cl {
 		s.color = cl
 		return &Change{
-			Color: &cl,
+			Color:   &cl,
+			Cursors: s.deviceCursors(),
+			Line:    s.pendingLine(),
 		}
 	}
 	return nil
 }
 
+func (s State) GetColor() common.Color {
+	return s.color
+}
+
+func (s State) GetDisplaySettings() hat.DisplaySettings {
+	return s.display
+}
+
+func (s *State) SetDisplaySettings(settings hat.DisplaySettings) (*Change, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	if s.display == settings {
+		return nil, nil
+	}
+
+	s.display = settings
+	return &Change{
+		DisplaySettings: &settings,
+	}, nil
+}
+
+// CycleColor sets the color to the next color in the palette
+func (s *State) CycleColor() *Change {
+	next := palette[0]
+	for i, cl := range palette {
+		if cl == s.color {
+			next = palette[(i+1)%len(palette)]
+			break
+		}
+	}
+
+	return s.SetColor(next)
+}
+
+// CycleTool sets the tool to the next tool
+func (s *State) CycleTool() *Change {
+	next := toolNames[0]
+	for i, name := range toolNames {
+		if name == s.toolName {
+			next = toolNames[(i+1)%len(toolNames)]
+			break
+		}
+	}
+
+	change, _ := s.SetTool(next)
+	return change
+}
+
 func (s *State) SetTool(toolName string) (*Change, error) {
 	if toolName == s.toolName {
 		return nil, nil
@@ -300,14 +504,25 @@ func (s *State) SetTool(toolName string) (*Change, error) {
 		s.tool = s.eraser
 	case bucketName:
 		s.tool = s.bucket
+	case lineName:
+		s.tool = s.line
 	default:
 		return nil, fmt.Errorf(`unknown tool "%s"`, toolName)
 	}
 
 	s.toolName = toolName
-	return &Change{
+	change := &Change{
 		ToolName: toolName,
-	}, nil
+		Cursors:  s.deviceCursors(),
+	}
+
+	// changing the tool cancels the pending line
+	if s.anchor != nil {
+		s.anchor = nil
+		change.Line = &LinePreview{}
+	}
+
+	return change, nil
 }
 
 func (s State) getPositionChange() *Change {
@@ -320,30 +535,64 @@ func (s State) getPositionChange() *Change {
 			X: s.window.X,
 			Y: s.window.Y,
 		},
+		Cursors: s.deviceCursors(),
+		Line:    s.pendingLine(),
 	}
 }
 
 func (s State) GetFullChange() *Change {
-	return &Change{
-		Canvas:   s.canvas.Clone(),
-		Cursor:   &s.cursor,
-		Window:   &s.window,
-		ToolName: s.toolName,
-		Color:    &s.color,
+	change := &Change{
+		Canvas:          s.canvas.Clone(),
+		Cursor:          &s.cursor,
+		Window:          &s.window,
+		ToolName:        s.toolName,
+		Color:           &s.color,
+		DisplaySettings: &s.display,
+		Zoom:            s.zoom,
+		Cursors:         s.deviceCursors(),
+		Line:            s.linePreview(),
 	}
+	return s.withHistory(change)
 }
 
+// Undo reverts the last step, and adds it to the redo list
 func (s *State) Undo() *Change {
-	chng := undoList.pop()
-	if chng != nil {
-		if chng.Canvas != nil {
-			s.canvas = chng.Canvas
-		} else if len(chng.Pixels) > 0 {
-			for _, pixel := range chng.Pixels {
-				s.canvas[pixel.Y][pixel.X] = pixel.Color
-			}
+	return s.step(&s.history.undo, &s.history.redo)
+}
+
+// Redo repeats the last undone step, and adds it back to the undo list
+func (s *State) Redo() *Change {
+	return s.step(&s.history.redo, &s.history.undo)
+}
+
+// step applies the last change of the from list, and adds the change that reverts it to the to list
+func (s *State) step(from, to *changeStack) *Change {
+	chng := s.history.pop(from)
+	if chng == nil {
+		return nil
+	}
+
+	reverse := &Change{}
+	if chng.Canvas != nil {
+		reverse.Canvas = s.canvas
+		s.canvas = chng.Canvas
+	} else if len(chng.Pixels) > 0 {
+		reverse.Pixels = make([]Pixel, len(chng.Pixels))
+		for i, pixel := range chng.Pixels {
+			reverse.Pixels[i] = Pixel{X: pixel.X, Y: pixel.Y, Color: s.canvas[pixel.Y][pixel.X]}
+			s.canvas[pixel.Y][pixel.X] = pixel.Color
 		}
 	}
+	s.history.add(to, reverse)
+
+	return s.withHistory(chng)
+}
 
-	return chng
+// withHistory sets the undo and redo availability of the change
+func (s State) withHistory(change *Change) *Change {
+	canUndo := s.history.undo.len() > 0
+	canRedo := s.history.redo.len() > 0
+	change.CanUndo = &canUndo
+	change.CanRedo = &canRedo
+	return change
 }
@@ -5,6 +5,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
 )
 
 const (
@@ -66,6 +67,14 @@ var _ = Describe("test state", func() {
 			Expect(msg.CursorX).Should(BeEquivalentTo(7))
 			Expect(msg.CursorY).Should(BeEquivalentTo(3))
 		})
+
+		It("Should set the window position", func() {
+			s.window.X = 2
+			s.window.Y = 5
+			msg := s.CreateDisplayMessage()
+			Expect(msg.WindowX).Should(BeEquivalentTo(2))
+			Expect(msg.WindowY).Should(BeEquivalentTo(5))
+		})
 	})
 
 	Context("test GoUp", func() {
@@ -83,7 +92,7 @@ var _ = Describe("test state", func() {
 			Expect(change.Cursor.Y).Should(BeEquivalentTo(y - 1))
 			Expect(change.Cursor.X).Should(BeEquivalentTo(x))
 
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 
 		It("should ignore if the cursor is at the top of the canvas", func() {
@@ -93,7 +102,7 @@ var _ = Describe("test state", func() {
 			Expect(s.cursor.Y).Should(BeEquivalentTo(0))
 			Expect(s.cursor.X).Should(BeEquivalentTo(x))
 
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 	})
 
@@ -112,7 +121,7 @@ var _ = Describe("test state", func() {
 			Expect(change.Cursor.Y).Should(BeEquivalentTo(y + 1))
 			Expect(change.Cursor.X).Should(BeEquivalentTo(x))
 
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 
 		It("should ignore if the cursor is at the bottom of the canvas", func() {
@@ -122,7 +131,7 @@ var _ = Describe("test state", func() {
 			Expect(s.cursor.Y).Should(BeEquivalentTo(canvasHeight - 1))
 			Expect(s.cursor.X).Should(BeEquivalentTo(x))
 
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 	})
 
@@ -141,7 +150,7 @@ var _ = Describe("test state", func() {
 			Expect(change.Cursor.Y).Should(BeEquivalentTo(y))
 			Expect(change.Cursor.X).Should(BeEquivalentTo(x - 1))
 
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 
 		It("should ignore if the cursor is at the most left column of the canvas", func() {
@@ -151,7 +160,7 @@ var _ = Describe("test state", func() {
 			Expect(s.cursor.Y).Should(BeEquivalentTo(y))
 			Expect(s.cursor.X).Should(BeEquivalentTo(0))
 
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 	})
 
@@ -170,7 +179,7 @@ var _ = Describe("test state", func() {
 			Expect(change.Cursor.Y).Should(BeEquivalentTo(y))
 			Expect(change.Cursor.X).Should(BeEquivalentTo(x + 1))
 
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 
 		It("should ignore if the cursor is at the most right column of the canvas", func() {
@@ -180,19 +189,84 @@ var _ = Describe("test state", func() {
 			Expect(s.cursor.Y).Should(BeEquivalentTo(y))
 			Expect(s.cursor.X).Should(BeEquivalentTo(canvasWidth - 1))
 
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 	})
 
-	Context("test StatePaintPixel", func() {
+	Context("test paging", func() {
 		var s *State
-
 		BeforeEach(func() {
 			s = NewState(canvasWidth, canvasHeight)
 		})
 
-		AfterEach(func() {
-			emptyUndoList()
+		It("should move the window and the cursor by a full window", func() {
+			change := s.PageRight()
+			Expect(change).ShouldNot(BeNil())
+			Expect(*change.Window).Should(Equal(window{X: 24, Y: 8}))
+			Expect(*change.Cursor).Should(Equal(cursor{X: 28, Y: 12}))
+
+			change = s.PageLeft()
+			Expect(*change.Window).Should(Equal(window{X: 16, Y: 8}))
+			Expect(*change.Cursor).Should(Equal(cursor{X: 20, Y: 12}))
+
+			change = s.PageUp()
+			Expect(*change.Window).Should(Equal(window{X: 16, Y: 0}))
+			Expect(*change.Cursor).Should(Equal(cursor{X: 20, Y: 4}))
+
+			change = s.PageDown()
+			Expect(*change.Window).Should(Equal(window{X: 16, Y: 8}))
+			Expect(*change.Cursor).Should(Equal(cursor{X: 20, Y: 12}))
+
+			Expect(s.history.undo.len()).Should(BeZero())
+		})
+
+		It("should stop at the edge of the canvas", func() {
+			s.window.X = 30
+			s.cursor.X = 31
+			change := s.PageRight()
+			Expect(*change.Window).Should(Equal(window{X: 32, Y: 8}))
+			Expect(*change.Cursor).Should(Equal(cursor{X: 33, Y: 12}))
+
+			Expect(s.PageRight()).Should(BeNil())
+
+			s.window.Y = 0
+			s.cursor.Y = 2
+			Expect(s.PageUp()).Should(BeNil())
+		})
+	})
+
+	Context("test cycling", func() {
+		var s *State
+		BeforeEach(func() {
+			s = NewState(8, 8)
+		})
+
+		It("should cycle the tools", func() {
+			Expect(s.CycleTool().ToolName).Should(Equal(eraserName))
+			Expect(s.CycleTool().ToolName).Should(Equal(bucketName))
+			Expect(s.CycleTool().ToolName).Should(Equal(lineName))
+			Expect(s.CycleTool().ToolName).Should(Equal(penName))
+		})
+
+		It("should cycle the palette colors", func() {
+			for i := 1; i <= len(palette); i++ {
+				change := s.CycleColor()
+				Expect(change).ShouldNot(BeNil())
+				Expect(*change.Color).Should(Equal(palette[i%len(palette)]))
+			}
+		})
+
+		It("should start from the first palette color, if the color is not in the palette", func() {
+			s.color = 0x123456
+			Expect(*s.CycleColor().Color).Should(Equal(palette[0]))
+		})
+	})
+
+	Context("test StatePaintPixel", func() {
+		var s *State
+
+		BeforeEach(func() {
+			s = NewState(canvasWidth, canvasHeight)
 		})
 
 		It("should be black when creating the state", func() {
@@ -206,19 +280,19 @@ var _ = Describe("test state", func() {
 			Expect(s.canvas[s.cursor.Y][s.cursor.X]).Should(Equal(common.Color(0xFFFFFF)))
 			Expect(change.Pixels).Should(HaveLen(1))
 			Expect(change.Pixels[0]).Should(Equal(Pixel{X: s.cursor.X, Y: s.cursor.Y, Color: common.Color(0xFFFFFF)}))
-			Expect(undoList.len()).Should(Equal(1))
+			Expect(s.history.undo.len()).Should(Equal(1))
 
 			By("undo")
-			change = undoList.pop()
+			change = s.history.undo.pop()
 			Expect(change.Pixels).Should(HaveLen(1))
 			Expect(change.Pixels[0]).Should(Equal(Pixel{X: s.cursor.X, Y: s.cursor.Y, Color: common.Color(0)}))
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 		})
 
 		It("should ignore if painting with the same color at the same place", func() {
 			change := s.Paint()
 			Expect(change).ToNot(BeNil())
-			_ = undoList.pop()
+			_ = s.history.undo.pop()
 
 			change = s.Paint()
 			Expect(change).To(BeNil())
@@ -229,7 +303,7 @@ var _ = Describe("test state", func() {
 			s.cursor.X = canvasWidth
 			change := s.Paint()
 			Expect(change).To(BeNil())
-			Expect(undoList.len()).Should(BeZero())
+			Expect(s.history.undo.len()).Should(BeZero())
 
 			s.cursor.X = canvasWidth / 2
 			s.cursor.Y = canvasHeight
@@ -255,6 +329,49 @@ var _ = Describe("test state", func() {
 		})
 	})
 
+	Context("Test SetDisplaySettings", func() {
+		var s *State
+		BeforeEach(func() {
+			s = NewState(8, 8)
+		})
+
+		It("should start with the default settings", func() {
+			Expect(s.GetDisplaySettings()).Should(Equal(hat.NewDisplaySettings()))
+
+			msg := s.CreateDisplayMessage()
+			Expect(msg.Settings).ShouldNot(BeNil())
+			Expect(*msg.Settings).Should(Equal(hat.NewDisplaySettings()))
+		})
+
+		It("should ignore if setting the same settings", func() {
+			change, err := s.SetDisplaySettings(hat.NewDisplaySettings())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(change).Should(BeNil())
+		})
+
+		It("should set the settings", func() {
+			settings := hat.NewDisplaySettings()
+			settings.Brightness = 30
+			settings.LowLight = true
+
+			change, err := s.SetDisplaySettings(settings)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*change).Should(Equal(Change{DisplaySettings: &settings}))
+			Expect(s.GetDisplaySettings()).Should(Equal(settings))
+			Expect(*s.CreateDisplayMessage().Settings).Should(Equal(settings))
+		})
+
+		It("should reject wrong settings", func() {
+			settings := hat.NewDisplaySettings()
+			settings.Brightness = 200
+
+			change, err := s.SetDisplaySettings(settings)
+			Expect(err).To(HaveOccurred())
+			Expect(change).Should(BeNil())
+			Expect(s.GetDisplaySettings()).Should(Equal(hat.NewDisplaySettings()))
+		})
+	})
+
 	Context("Test SetTool", func() {
 		var s *State
 		BeforeEach(func() {
@@ -316,7 +433,7 @@ var _ = Describe("test state", func() {
 				}},
 			}
 
-			undoList.push(c)
+			s.history.push(c)
 			By("perform the undo")
 			s.Undo()
 
@@ -325,6 +442,31 @@ var _ = Describe("test state", func() {
 		})
 	})
 
+	Context("test PaintRegion", func() {
+		It("should paint the region inside the canvas, without undo", func() {
+			s := NewState(canvasWidth, canvasHeight)
+
+			region := [][]common.Color{
+				{1, 2, 3},
+				{4, 5, 6},
+			}
+
+			change := s.PaintRegion(canvasWidth-2, 3, region)
+			Expect(change).ShouldNot(BeNil())
+			Expect(change.Pixels).Should(Equal([]Pixel{
+				{X: canvasWidth - 2, Y: 3, Color: 1},
+				{X: canvasWidth - 1, Y: 3, Color: 2},
+				{X: canvasWidth - 2, Y: 4, Color: 4},
+				{X: canvasWidth - 1, Y: 4, Color: 5},
+			}))
+			Expect(s.canvas[4][canvasWidth-1]).Should(BeEquivalentTo(5))
+			Expect(s.history.undo.len()).Should(BeZero())
+
+			By("returning nil if nothing was changed")
+			Expect(s.PaintRegion(canvasWidth-2, 3, region)).Should(BeNil())
+		})
+	})
+
 	Context("test bucket", func() {
 		var s *State
 
@@ -464,26 +606,14 @@ var _ = Describe("test state", func() {
 			Expect(s.canvas).Should(Equal(expected))
 		})
 
-		It("should undo", func() {
+		It("should undo and redo", func() {
 			s.cursor.X = 7
 			s.cursor.Y = 7
 
 			_, _ = s.SetTool(bucketName)
+			s.color = 5
 
-			s.canvas = Canvas{
-				{5, 5, 5, 5, 5, 5, 5, 5},
-				{5, 5, 5, 5, 5, 5, 5, 5},
-				{5, 5, 3, 3, 3, 3, 3, 5},
-				{5, 5, 3, 2, 2, 2, 3, 5},
-				{5, 5, 3, 2, 2, 2, 3, 5},
-				{5, 5, 3, 2, 2, 2, 3, 5},
-				{5, 5, 3, 3, 3, 3, 3, 5},
-				{5, 5, 5, 5, 5, 5, 5, 5},
-			}
-
-			s.Undo()
-
-			expected := Canvas{
+			before := Canvas{
 				{4, 4, 4, 4, 4, 4, 4, 4},
 				{4, 4, 4, 4, 4, 4, 4, 4},
 				{4, 4, 3, 3, 3, 3, 3, 4},
@@ -493,8 +623,20 @@ var _ = Describe("test state", func() {
 				{4, 4, 3, 3, 3, 3, 3, 4},
 				{4, 4, 4, 4, 4, 4, 4, 4},
 			}
+			s.canvas = before.Clone()
 
-			Expect(s.canvas).Should(Equal(expected))
+			s.Paint()
+			after := s.canvas.Clone()
+
+			change := s.Undo()
+			Expect(*change.CanUndo).Should(BeFalse())
+			Expect(*change.CanRedo).Should(BeTrue())
+			Expect(s.canvas).Should(Equal(before))
+
+			change = s.Redo()
+			Expect(*change.CanUndo).Should(BeTrue())
+			Expect(*change.CanRedo).Should(BeFalse())
+			Expect(s.canvas).Should(Equal(after))
 		})
 
 		It("fill the entire canvas", func() {
@@ -532,9 +674,3 @@ var _ = Describe("test state", func() {
 		})
 	})
 })
-
-func emptyUndoList() {
-	for undoList.len() > 0 {
-		_ = undoList.pop()
-	}
-}
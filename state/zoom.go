@@ -0,0 +1,71 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+// the supported zoom levels; each canvas pixel is displayed as a zoom X zoom LED block
+var zoomLevels = []uint8{1, 2, 4}
+
+// windowSize is the number of canvas pixels in each row and column of the window, in the current zoom level
+func (s State) windowSize() uint8 {
+	return common.WindowSize / s.zoom
+}
+
+// SetZoom changes the zoom level, and centers the window around the cursor
+func (s *State) SetZoom(zoom uint8) (*Change, error) {
+	found := false
+	for _, level := range zoomLevels {
+		found = found || level == zoom
+	}
+	if !found {
+		return nil, fmt.Errorf("wrong zoom level %d; should be one of %v", zoom, zoomLevels)
+	}
+
+	if zoom == s.zoom {
+		return nil, nil
+	}
+
+	s.zoom = zoom
+	s.window = window{
+		X: centerWindow(s.cursor.X, s.windowSize(), s.canvasWidth),
+		Y: centerWindow(s.cursor.Y, s.windowSize(), s.canvasHeight),
+	}
+
+	change := s.getPositionChange()
+	change.Zoom = zoom
+	return change, nil
+}
+
+// ZoomIn sets the next zoom level, if the current level is not the highest
+func (s *State) ZoomIn() *Change {
+	for i, level := range zoomLevels[:len(zoomLevels)-1] {
+		if level == s.zoom {
+			change, _ := s.SetZoom(zoomLevels[i+1])
+			return change
+		}
+	}
+	return nil
+}
+
+// ZoomOut sets the previous zoom level, if the current level is not the lowest
+func (s *State) ZoomOut() *Change {
+	for i, level := range zoomLevels[1:] {
+		if level == s.zoom {
+			change, _ := s.SetZoom(zoomLevels[i])
+			return change
+		}
+	}
+	return nil
+}
+
+// centerWindow returns the window position that places the cursor in the middle of the window, inside the canvas
+func centerWindow(cursor, windowSize, canvasSize uint8) uint8 {
+	if cursor < windowSize/2 {
+		return 0
+	}
+
+	return minUint8(cursor-windowSize/2, canvasSize-windowSize)
+}
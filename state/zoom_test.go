@@ -0,0 +1,94 @@
+package state
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+)
+
+var _ = Describe("test zoom", func() {
+	var s *State
+
+	BeforeEach(func() {
+		s = NewState(canvasWidth, canvasHeight)
+	})
+
+	It("should start without zoom", func() {
+		Expect(s.zoom).Should(BeEquivalentTo(1))
+		Expect(s.GetFullChange().Zoom).Should(BeEquivalentTo(1))
+		Expect(s.CreateDisplayMessage().Zoom).Should(BeEquivalentTo(1))
+	})
+
+	It("should center the window around the cursor", func() {
+		change, err := s.SetZoom(4)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(change.Zoom).Should(BeEquivalentTo(4))
+		Expect(*change.Cursor).Should(Equal(cursor{X: 20, Y: 12}))
+		Expect(*change.Window).Should(Equal(window{X: 19, Y: 11}))
+
+		change, err = s.SetZoom(2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*change.Window).Should(Equal(window{X: 18, Y: 10}))
+
+		By("keep the window inside the canvas")
+		s.cursor = cursor{X: canvasWidth - 1, Y: 0}
+		change, err = s.SetZoom(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*change.Window).Should(Equal(window{X: canvasWidth - 8, Y: 0}))
+	})
+
+	It("should ignore the same zoom", func() {
+		change, err := s.SetZoom(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(change).Should(BeNil())
+	})
+
+	It("should reject wrong zoom", func() {
+		change, err := s.SetZoom(3)
+		Expect(err).To(HaveOccurred())
+		Expect(change).Should(BeNil())
+		Expect(s.zoom).Should(BeEquivalentTo(1))
+	})
+
+	It("should zoom in and out", func() {
+		Expect(s.ZoomOut()).Should(BeNil())
+		Expect(s.ZoomIn().Zoom).Should(BeEquivalentTo(2))
+		Expect(s.ZoomIn().Zoom).Should(BeEquivalentTo(4))
+		Expect(s.ZoomIn()).Should(BeNil())
+		Expect(s.ZoomOut().Zoom).Should(BeEquivalentTo(2))
+		Expect(s.ZoomOut().Zoom).Should(BeEquivalentTo(1))
+	})
+
+	It("should display each pixel as a block", func() {
+		_, _ = s.SetZoom(2)
+		s.canvas[10][18] = 0x123456
+		s.canvas[12][20] = 0x654321
+
+		msg := s.CreateDisplayMessage()
+		Expect(msg.Zoom).Should(BeEquivalentTo(2))
+		Expect(msg.CursorX).Should(BeEquivalentTo(4))
+		Expect(msg.CursorY).Should(BeEquivalentTo(4))
+		Expect(msg.WindowX).Should(BeEquivalentTo(18))
+		Expect(msg.WindowY).Should(BeEquivalentTo(10))
+
+		for _, p := range [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+			Expect(msg.Screen[p[1]][p[0]]).Should(Equal(common.Color(0x123456)))
+			Expect(msg.Screen[4+p[1]][4+p[0]]).Should(Equal(common.Color(0x654321)))
+		}
+		Expect(msg.Screen[2][2]).Should(Equal(common.Color(0)))
+	})
+
+	It("should move the window with the smaller window size", func() {
+		_, _ = s.SetZoom(4)
+		// the window is 2x2; the cursor is at its right column
+		Expect(s.GoRight().Window.X).Should(BeEquivalentTo(20))
+		Expect(s.GoDown().Window.Y).Should(BeEquivalentTo(12))
+		Expect(s.GoLeft().Window.X).Should(BeEquivalentTo(20))
+		Expect(s.GoLeft().Window.X).Should(BeEquivalentTo(19))
+
+		change := s.PageRight()
+		Expect(*change.Window).Should(Equal(window{X: 21, Y: 12}))
+		Expect(*change.Cursor).Should(Equal(cursor{X: 21, Y: 13}))
+	})
+})
@@ -0,0 +1,110 @@
+package webapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/nunnatsa/piHatDraw/hat"
+	"github.com/nunnatsa/piHatDraw/state"
+)
+
+const clientTimeout = 5 * time.Second
+
+// Client is a client of another piHatDraw instance; e.g. the leader of a video wall
+type Client struct {
+	registerURL string
+	joystickURL string
+	httpClient  *http.Client
+	dialer      *websocket.Dialer
+}
+
+// NewClient creates a client of the instance in address; e.g. "pi1:8080" or "http://pi1:8080"
+func NewClient(address string) (*Client, error) {
+	base, err := url.Parse(address)
+	if err != nil || base.Host == "" {
+		// no scheme; e.g. "pi1:8080"
+		base, err = url.Parse("http://" + address)
+		if err != nil {
+			return nil, fmt.Errorf("wrong address %q; %w", address, err)
+		}
+	}
+
+	if base.Scheme != "http" {
+		return nil, fmt.Errorf("wrong address %q; only http is supported", address)
+	}
+
+	register := *base
+	register.Scheme = "ws"
+	register.Path = "/api/canvas/register"
+
+	joystick := *base
+	joystick.Path = "/api/joystick"
+
+	return &Client{
+		registerURL: register.String(),
+		joystickURL: joystick.String(),
+		httpClient:  &http.Client{Timeout: clientTimeout},
+		dialer:      &websocket.Dialer{HandshakeTimeout: clientTimeout},
+	}, nil
+}
+
+// Connection is a websocket connection to the canvas changes. The first change is the full state of the canvas.
+type Connection struct {
+	conn *websocket.Conn
+}
+
+// Connect subscribes to the canvas changes
+func (c Client) Connect() (*Connection, error) {
+	conn, _, err := c.dialer.Dial(c.registerURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to %s; %w", c.registerURL, err)
+	}
+
+	return &Connection{conn: conn}, nil
+}
+
+// Next blocks until the next change is received
+func (c Connection) Next() (*state.Change, error) {
+	_, js, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	change := &state.Change{}
+	if err = json.Unmarshal(js, change); err != nil {
+		return nil, fmt.Errorf("can't parse the change; %w", err)
+	}
+
+	return change, nil
+}
+
+// Close closes the connection. A blocked Next call returns with an error.
+func (c Connection) Close() error {
+	return c.conn.Close()
+}
+
+// SendJoystickEvent sends a joystick event to the instance, as if it came from its own joystick
+func (c Client) SendJoystickEvent(e hat.Event) error {
+	body, err := json.Marshal(joystickRq{Event: e.String()})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Post(c.joystickURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to send the joystick event %v; status: %s", e, resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package webapp
+
+import (
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
+	"github.com/nunnatsa/piHatDraw/notifier"
+)
+
+var _ = Describe("test the client", func() {
+	DescribeTable("should parse the address", func(address, registerURL string) {
+		client, err := NewClient(address)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(client.registerURL).Should(Equal(registerURL))
+	},
+		Entry("host and port", "pi1:8080", "ws://pi1:8080/api/canvas/register"),
+		Entry("IP and port", "127.0.0.1:8080", "ws://127.0.0.1:8080/api/canvas/register"),
+		Entry("URL", "http://pi1:8080", "ws://pi1:8080/api/canvas/register"),
+	)
+
+	It("should reject a wrong address", func() {
+		_, err := NewClient("https://pi1:8080")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with a server", func() {
+		var (
+			n      *notifier.Notifier
+			ce     chan ClientEvent
+			server *httptest.Server
+			client *Client
+		)
+
+		BeforeEach(func() {
+			n = notifier.NewNotifier()
+			ce = make(chan ClientEvent, 1)
+			server = httptest.NewServer(NewWebApplication(n, ce).GetMux())
+
+			var err error
+			client, err = NewClient(server.URL)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			n.Close()
+			server.Close()
+			close(ce)
+		})
+
+		It("should receive the changes", func() {
+			conn, err := client.Connect()
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			var id ClientEventRegistered
+			Eventually(ce).Should(Receive(&id))
+			n.NotifyOne(uint64(id), []byte(`{"toolName": "eraser", "pixels": [{"x": 1, "y": 2, "color": "#ff0000"}]}`))
+
+			change, err := conn.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(change.ToolName).Should(Equal("eraser"))
+			Expect(change.Pixels).Should(HaveLen(1))
+			Expect(change.Pixels[0].Color).Should(Equal(common.Color(0xFF0000)))
+
+			n.NotifyOne(uint64(id), []byte(`bad json`))
+			_, err = conn.Next()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should send joystick events", func() {
+			Expect(client.SendJoystickEvent(hat.DoublePressed)).To(Succeed())
+			Expect(<-ce).Should(Equal(ClientEventJoystick(hat.DoublePressed)))
+		})
+
+		It("should fail to connect to a closed server", func() {
+			server.Close()
+			_, err := client.Connect()
+			Expect(err).To(HaveOccurred())
+			Expect(client.SendJoystickEvent(hat.Pressed)).ToNot(Succeed())
+		})
+	})
+})
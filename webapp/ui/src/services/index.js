@@ -17,6 +17,11 @@ export default {
             axios.post(`${basePath}/undo`, {undo: true})
         }
     },
+    redo() {
+        if (initialized) {
+            axios.post(`${basePath}/redo`, {redo: true})
+        }
+    },
     setTool(toolName) {
         if (initialized) {
             axios.post(`${basePath}/tool`, {toolName: toolName})
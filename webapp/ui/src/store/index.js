@@ -1,5 +1,15 @@
 import {createStore} from 'vuex'
 
+export function toolChar(toolName) {
+    switch (toolName) {
+        case "pen": return "+"
+        case "eraser": return "x"
+        case "bucket": return "o"
+        case "line": return "/"
+        default: return "?"
+    }
+}
+
 export const store = createStore({
     state: {initializing: true},
     mutations: {
@@ -14,13 +24,18 @@ export const store = createStore({
                 }
             }
 
+            if (data.zoom) {
+                newState.zoom = data.zoom
+            }
+
             if (data.window) {
                 const win = data.window
+                const size = 8 / (newState.zoom || 1)
                 newState.window = {
                     top: win.y,
                     left: win.x,
-                    bottom: win.y + 7,
-                    right: win.x + 7,
+                    bottom: win.y + size - 1,
+                    right: win.x + size - 1,
                 }
             }
 
@@ -31,16 +46,32 @@ export const store = createStore({
                 newState.color = data.color
             }
 
+            if (data.canUndo !== undefined) {
+                newState.canUndo = data.canUndo
+            }
+            if (data.canRedo !== undefined) {
+                newState.canRedo = data.canRedo
+            }
+
+            if (data.displaySettings) {
+                newState.displaySettings = Object.assign({}, data.displaySettings)
+            }
+
+            // the pending line of the line tool, by "x,y"
+            if (data.line) {
+                newState.linePreview = {}
+                for (const pixel of data.line.pixels || []) {
+                    newState.linePreview[`${pixel.x},${pixel.y}`] = pixel.color
+                }
+            }
+
+            if (data.cursors) {
+                newState.cursors = data.cursors
+            }
+
             if (data.toolName) {
                 newState.tool = data.toolName
-                let toolChar
-                switch (data.toolName){
-                    case "pen": toolChar = "+"; break;
-                    case "eraser": toolChar = "x"; break;
-                    case "bucket": toolChar = "o"; break;
-                    default: toolChar = "?"; break;
-                }
-                newState.toolChar = toolChar
+                newState.toolChar = toolChar(data.toolName)
             }
 
             newState.initializing = false
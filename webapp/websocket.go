@@ -2,17 +2,22 @@ package webapp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
 	"github.com/nunnatsa/piHatDraw/notifier"
 )
 
@@ -40,6 +45,66 @@ type ClientEventDownload chan [][]common.Color
 
 type ClientEventUndo bool
 
+type ClientEventRedo bool
+
+// ClientEventDrawLine draws a line between two canvas points. Color is the current color if it's nil. The result of the
+// request is sent to Result.
+type ClientEventDrawLine struct {
+	X0, Y0 uint8
+	X1, Y1 uint8
+	Color  *common.Color
+	Result chan error
+}
+
+// ClientEventSetDisplaySettings changes the LED matrix color management. Only the non-nil fields are changed. If Result
+// is not nil, the validation error of the new settings is sent to it.
+type ClientEventSetDisplaySettings struct {
+	Gamma      *hat.GammaTable     `json:"gamma"`
+	Brightness *uint8              `json:"brightness"`
+	LowLight   *bool               `json:"lowLight"`
+	Dithering  *string             `json:"dithering"`
+	Downsample *string             `json:"downsample"`
+	Cursor     *hat.CursorSettings `json:"cursor"`
+	Result     chan error          `json:"-"`
+}
+
+type ClientEventSetZoom uint8
+
+// ClientEventPan moves the display window without moving the cursor; by DX, DY pixels, or to X, Y if Absolute
+type ClientEventPan struct {
+	DX, DY   int
+	X, Y     int
+	Absolute bool
+}
+
+// ClientEventSnapToCursor moves the display window back to the cursor
+type ClientEventSnapToCursor bool
+
+// ClientEventShowText scrolls a text on the display
+type ClientEventShowText hat.TextMessage
+
+// ClientEventDisplayHealth requests the health of the display sinks
+type ClientEventDisplayHealth chan []hat.SinkHealth
+
+// ClientEventPlot starts the sensor plot, or stops it if Sensor is empty. The zero values are replaced by the defaults.
+// The result of the request is sent to Result.
+type ClientEventPlot struct {
+	Sensor   string
+	X        uint8
+	Y        uint8
+	Width    uint8
+	Height   uint8
+	Style    string
+	Min      float64
+	Max      float64
+	Colors   []common.Color
+	Interval time.Duration
+	Result   chan error
+}
+
+// ClientEventJoystick is a joystick event from a remote joystick; e.g. of a follower
+type ClientEventJoystick hat.Event
+
 type WebApplication struct {
 	mux          *http.ServeMux
 	notifier     *notifier.Notifier
@@ -61,6 +126,16 @@ func NewWebApplication(mailbox *notifier.Notifier, ch chan<- ClientEvent) *WebAp
 	mux.Handle("/api/canvas/reset", PostOnlyRequest(ca.reset))
 	mux.Handle("/api/canvas/download", GetOnlyRequest(ca.downloadImage))
 	mux.Handle("/api/canvas/undo", PostOnlyRequest(ca.undo))
+	mux.Handle("/api/canvas/redo", PostOnlyRequest(ca.redo))
+	mux.Handle("/api/display/settings", PostOnlyRequest(ca.setDisplaySettings))
+	mux.Handle("/api/display/zoom", PostOnlyRequest(ca.setZoom))
+	mux.Handle("/api/display/pan", PostOnlyRequest(ca.pan))
+	mux.Handle("/api/display/snap", PostOnlyRequest(ca.snap))
+	mux.Handle("/api/display/health", GetOnlyRequest(ca.displayHealth))
+	mux.Handle("/api/display/text", PostOnlyRequest(ca.showText))
+	mux.Handle("/api/joystick", PostOnlyRequest(ca.joystick))
+	mux.Handle("/api/canvas/plot", PostOnlyRequest(ca.plot))
+	mux.Handle("/api/canvas/line", PostOnlyRequest(ca.drawLine))
 
 	return ca
 }
@@ -83,6 +158,8 @@ func (ca WebApplication) register(w http.ResponseWriter, r *http.Request) {
 	defer ca.notifier.Unsubscribe(id)
 	ca.clientEvents <- ClientEventRegistered(id)
 
+	go ca.readCommands(conn, id)
+
 	for js := range subscription {
 		log.Printf("got event; updating client %d\n", id)
 		if err := conn.WriteMessage(websocket.TextMessage, js); err != nil {
@@ -94,6 +171,44 @@ func (ca WebApplication) register(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Connection %d is closed\n", id)
 }
 
+// wsCommand is a command that the web client sends over the websocket; e.g. {"command": "pan", "dx": 1}
+type wsCommand struct {
+	Command string `json:"command"`
+	panRq
+}
+
+// readCommands reads the commands of the web client, until the connection is closed
+func (ca WebApplication) readCommands(conn *websocket.Conn, id uint64) {
+	for {
+		_, js, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		cmd := &wsCommand{}
+		if err = json.Unmarshal(js, cmd); err != nil {
+			log.Printf("can't parse the command of the client %d: %v\n", id, err)
+			continue
+		}
+
+		switch cmd.Command {
+		case "pan":
+			clientEvent, err := cmd.panRq.clientEvent()
+			if err != nil {
+				log.Printf("wrong pan command of the client %d: %v\n", id, err)
+				continue
+			}
+			ca.clientEvents <- clientEvent
+
+		case "snap":
+			ca.clientEvents <- ClientEventSnapToCursor(true)
+
+		default:
+			log.Printf("unknown command of the client %d: %q\n", id, cmd.Command)
+		}
+	}
+}
+
 type setColorRq struct {
 	Color common.Color `json:"color"`
 }
@@ -189,7 +304,7 @@ func (ca WebApplication) downloadImage(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, `{"error": "%v"}`, err)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
 		return
 	}
 
@@ -222,6 +337,313 @@ func (ca WebApplication) undo(w http.ResponseWriter, r *http.Request) {
 	ca.clientEvents <- clientEvent
 }
 
+type redoRq struct {
+	Redo bool `json:"redo"`
+}
+
+func (ca WebApplication) redo(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &redoRq{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json'"}`)
+		return
+	}
+
+	if msg.Redo {
+		log.Printf("Got redo request")
+	}
+
+	clientEvent := ClientEventRedo(true)
+	ca.clientEvents <- clientEvent
+}
+
+func (ca WebApplication) setDisplaySettings(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &ClientEventSetDisplaySettings{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json"}`)
+		return
+	}
+
+	log.Printf("Got set display settings request")
+
+	msg.Result = make(chan error, 1)
+	ca.clientEvents <- *msg
+
+	if err = <-msg.Result; err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+	}
+}
+
+type setZoomRq struct {
+	Zoom uint8 `json:"zoom"`
+}
+
+func (ca WebApplication) setZoom(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &setZoomRq{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json'"}`)
+		return
+	}
+
+	log.Printf("Got set zoom request. zoom = %d", msg.Zoom)
+
+	clientEvent := ClientEventSetZoom(msg.Zoom)
+	ca.clientEvents <- clientEvent
+}
+
+// panRq moves the window by dx, dy pixels, or to x, y if both are set
+type panRq struct {
+	DX int  `json:"dx"`
+	DY int  `json:"dy"`
+	X  *int `json:"x"`
+	Y  *int `json:"y"`
+}
+
+func (rq panRq) clientEvent() (ClientEventPan, error) {
+	if rq.X == nil && rq.Y == nil {
+		return ClientEventPan{DX: rq.DX, DY: rq.DY}, nil
+	}
+
+	if rq.X == nil || rq.Y == nil {
+		return ClientEventPan{}, errors.New("both x and y are required")
+	}
+
+	return ClientEventPan{X: *rq.X, Y: *rq.Y, Absolute: true}, nil
+}
+
+func (ca WebApplication) pan(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &panRq{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json'"}`)
+		return
+	}
+
+	clientEvent, err := msg.clientEvent()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+
+	log.Printf("Got pan request. %+v", clientEvent)
+
+	ca.clientEvents <- clientEvent
+}
+
+type snapRq struct {
+	Snap bool `json:"snap"`
+}
+
+func (ca WebApplication) snap(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &snapRq{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json'"}`)
+		return
+	}
+
+	log.Printf("Got snap to cursor request")
+
+	ca.clientEvents <- ClientEventSnapToCursor(true)
+}
+
+type linePointRq struct {
+	X uint8 `json:"x"`
+	Y uint8 `json:"y"`
+}
+
+type drawLineRq struct {
+	From  *linePointRq  `json:"from"`
+	To    *linePointRq  `json:"to"`
+	Color *common.Color `json:"color"`
+}
+
+func (ca WebApplication) drawLine(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &drawLineRq{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json'"}`)
+		return
+	}
+
+	if msg.From == nil || msg.To == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "both from and to are required"}`)
+		return
+	}
+
+	log.Printf("Got draw line request. from = %+v, to = %+v", *msg.From, *msg.To)
+
+	clientEvent := ClientEventDrawLine{
+		X0:     msg.From.X,
+		Y0:     msg.From.Y,
+		X1:     msg.To.X,
+		Y1:     msg.To.Y,
+		Color:  msg.Color,
+		Result: make(chan error, 1),
+	}
+	ca.clientEvents <- clientEvent
+
+	if err = <-clientEvent.Result; err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+	}
+}
+
+type showTextRq struct {
+	Text  string        `json:"text"`
+	Color *common.Color `json:"color"`
+}
+
+func (ca WebApplication) showText(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &showTextRq{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json'"}`)
+		return
+	}
+
+	if msg.Text == "" || utf8.RuneCountInString(msg.Text) > hat.MaxTextLength {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": "the text must be 1 to %d characters long"}`, hat.MaxTextLength)
+		return
+	}
+
+	textColor := common.Color(0xFFFFFF)
+	if msg.Color != nil {
+		textColor = *msg.Color
+	}
+
+	log.Printf("Got show text request. text = %q", msg.Text)
+
+	ca.clientEvents <- ClientEventShowText{Text: msg.Text, Color: textColor}
+}
+
+type joystickRq struct {
+	Event string `json:"event"`
+}
+
+func (ca WebApplication) joystick(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &joystickRq{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json'"}`)
+		return
+	}
+
+	event, err := hat.ParseEvent(msg.Event)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		return
+	}
+
+	log.Printf("Got joystick request. event = %v", event)
+
+	ca.clientEvents <- ClientEventJoystick(event)
+}
+
+type plotRq struct {
+	Stop     bool           `json:"stop"`
+	Sensor   string         `json:"sensor"`
+	X        uint8          `json:"x"`
+	Y        uint8          `json:"y"`
+	Width    uint8          `json:"width"`
+	Height   uint8          `json:"height"`
+	Style    string         `json:"style"`
+	Min      float64        `json:"min"`
+	Max      float64        `json:"max"`
+	Colors   []common.Color `json:"colors"`
+	Interval string         `json:"interval"`
+}
+
+func (ca WebApplication) plot(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewDecoder(r.Body)
+	msg := &plotRq{}
+	err := enc.Decode(msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "can't parse json'"}`)
+		return
+	}
+
+	event := ClientEventPlot{Result: make(chan error, 1)}
+	if !msg.Stop {
+		if msg.Sensor == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": "missing the sensor"}`)
+			return
+		}
+
+		var interval time.Duration
+		if msg.Interval != "" {
+			if interval, err = time.ParseDuration(msg.Interval); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"error": %q}`, err.Error())
+				return
+			}
+		}
+
+		event.Sensor = msg.Sensor
+		event.X, event.Y = msg.X, msg.Y
+		event.Width, event.Height = msg.Width, msg.Height
+		event.Style = msg.Style
+		event.Min, event.Max = msg.Min, msg.Max
+		event.Colors = msg.Colors
+		event.Interval = interval
+	}
+
+	log.Printf("Got plot request. sensor = %q", event.Sensor)
+
+	ca.clientEvents <- event
+	if err = <-event.Result; err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": %q}`, err.Error())
+	}
+}
+
+func (ca WebApplication) displayHealth(w http.ResponseWriter, _ *http.Request) {
+	healthChannel := make(chan []hat.SinkHealth, 1)
+	defer close(healthChannel)
+	ca.clientEvents <- ClientEventDisplayHealth(healthChannel)
+	health := <-healthChannel
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		log.Println("failed to send the display health;", err)
+	}
+}
+
+// WriteImage writes the canvas as a PNG image; each canvas pixel is a square of pixelSize X pixelSize image pixels
+func WriteImage(w io.Writer, imageData [][]common.Color, pixelSize int) error {
+	imageCanvas, err := getImageCanvas(imageData, pixelSize)
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(w, imageCanvas)
+}
+
 func getImageCanvas(imageData [][]common.Color, pixelSize int) (*image.RGBA, error) {
 	height := len(imageData) * pixelSize
 	if height == 0 {
@@ -1,6 +1,7 @@
 package webapp
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,6 +18,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/nunnatsa/piHatDraw/common"
+	"github.com/nunnatsa/piHatDraw/hat"
 	"github.com/nunnatsa/piHatDraw/notifier"
 )
 
@@ -88,6 +90,24 @@ var _ = Describe("Test the web application", func() {
 			}
 		})
 
+		It("should read the commands of the client", func() {
+			url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/canvas/register"
+
+			ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+			Expect(err).ToNot(HaveOccurred())
+			defer ws.Close()
+			Expect(<-ce).Should(BeAssignableToTypeOf(ClientEventRegistered(0)))
+
+			Expect(ws.WriteMessage(websocket.TextMessage, []byte(`{"command": "pan", "dx": 1}`))).To(Succeed())
+			Expect(<-ce).Should(Equal(ClientEventPan{DX: 1}))
+
+			By("ignoring the wrong commands")
+			Expect(ws.WriteMessage(websocket.TextMessage, []byte(`{"command": "pan", "x": 1}`))).To(Succeed())
+			Expect(ws.WriteMessage(websocket.TextMessage, []byte(`{"command": "fly"}`))).To(Succeed())
+			Expect(ws.WriteMessage(websocket.TextMessage, []byte(`{"command": "snap"}`))).To(Succeed())
+			Expect(<-ce).Should(Equal(ClientEventSnapToCursor(true)))
+		})
+
 		It("should reject if the method is wrong", func() {
 			url := server.URL + "/api/canvas/register"
 
@@ -135,6 +155,14 @@ var _ = Describe("Test the web application", func() {
 			Entry("test set tool request", "/api/canvas/tool", `{"toolName": "pen"}`, "pen"),
 			Entry("test reset request", "/api/canvas/reset", `{"reset": true}`, true),
 			Entry("test undo request", "/api/canvas/undo", `{"undo": true}`, true),
+			Entry("test redo request", "/api/canvas/redo", `{"redo": true}`, ClientEventRedo(true)),
+			Entry("test set zoom request", "/api/display/zoom", `{"zoom": 2}`, 2),
+			Entry("test pan request", "/api/display/pan", `{"dx": -1, "dy": 2}`, ClientEventPan{DX: -1, DY: 2}),
+			Entry("test pan to position request", "/api/display/pan", `{"x": 3, "y": 0}`, ClientEventPan{X: 3, Y: 0, Absolute: true}),
+			Entry("test snap request", "/api/display/snap", `{"snap": true}`, ClientEventSnapToCursor(true)),
+			Entry("test show text request", "/api/display/text", `{"text": "hello", "color": "#00ff00"}`, ClientEventShowText{Text: "hello", Color: 0x00FF00}),
+			Entry("test show text request without color", "/api/display/text", `{"text": "hello"}`, ClientEventShowText{Text: "hello", Color: 0xFFFFFF}),
+			Entry("test joystick request", "/api/joystick", `{"event": "MoveUp"}`, ClientEventJoystick(hat.MoveUp)),
 		)
 
 		DescribeTable("should reject if not a POST request", func(url string) {
@@ -148,6 +176,15 @@ var _ = Describe("Test the web application", func() {
 			Entry("wrong method in set tool request", "/api/canvas/tool"),
 			Entry("wrong method in reset request", "/api/canvas/reset"),
 			Entry("wrong method in undo request", "/api/canvas/undo"),
+			Entry("wrong method in redo request", "/api/canvas/redo"),
+			Entry("wrong method in set display settings request", "/api/display/settings"),
+			Entry("wrong method in set zoom request", "/api/display/zoom"),
+			Entry("wrong method in pan request", "/api/display/pan"),
+			Entry("wrong method in snap request", "/api/display/snap"),
+			Entry("wrong method in show text request", "/api/display/text"),
+			Entry("wrong method in joystick request", "/api/joystick"),
+			Entry("wrong method in plot request", "/api/canvas/plot"),
+			Entry("wrong method in line request", "/api/canvas/line"),
 		)
 
 		DescribeTable("should reject if not the body is in wrong json format", func(url string) {
@@ -162,7 +199,200 @@ var _ = Describe("Test the web application", func() {
 			Entry("wrong json in set tool request", "/api/canvas/tool"),
 			Entry("wrong json in reset request", "/api/canvas/reset"),
 			Entry("wrong json in undo request", "/api/canvas/undo"),
+			Entry("wrong json in redo request", "/api/canvas/redo"),
+			Entry("wrong json in set display settings request", "/api/display/settings"),
+			Entry("wrong json in set zoom request", "/api/display/zoom"),
+			Entry("wrong json in pan request", "/api/display/pan"),
+			Entry("wrong json in snap request", "/api/display/snap"),
+			Entry("wrong json in show text request", "/api/display/text"),
+			Entry("wrong json in joystick request", "/api/joystick"),
+			Entry("wrong json in plot request", "/api/canvas/plot"),
+			Entry("wrong json in line request", "/api/canvas/line"),
 		)
+
+		DescribeTable("should reject a wrong text", func(text string) {
+			url := server.URL + "/api/display/text"
+
+			body, err := json.Marshal(map[string]string{"text": text})
+			Expect(err).ToNot(HaveOccurred())
+			res, err := server.Client().Post(url, "application/json", bytes.NewReader(body))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).Should(Equal(http.StatusBadRequest))
+			Consistently(ce).ShouldNot(Receive())
+		},
+			Entry("empty text", ""),
+			Entry("too long text", strings.Repeat("a", hat.MaxTextLength+1)),
+		)
+
+		It("should reject a pan request with only one coordinate", func() {
+			res, err := server.Client().Post(server.URL+"/api/display/pan", "application/json", strings.NewReader(`{"x": 3}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).Should(Equal(http.StatusBadRequest))
+			Consistently(ce).ShouldNot(Receive())
+		})
+
+		It("should reject an unknown joystick event", func() {
+			url := server.URL + "/api/joystick"
+
+			res, err := server.Client().Post(url, "application/json", strings.NewReader(`{"event": "Jump"}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).Should(Equal(http.StatusBadRequest))
+			Consistently(ce).ShouldNot(Receive())
+		})
+
+		Context("test line request", func() {
+			post := func(reqBody string, replyErr error) (ClientEventDrawLine, int) {
+				resCh := make(chan *http.Response, 1)
+				go func() {
+					defer GinkgoRecover()
+					res, err := server.Client().Post(server.URL+"/api/canvas/line", "application/json", strings.NewReader(reqBody))
+					Expect(err).ToNot(HaveOccurred())
+					resCh <- res
+				}()
+
+				var event ClientEventDrawLine
+				Eventually(ce).Should(Receive(&event))
+				event.Result <- replyErr
+
+				res := <-resCh
+				return event, res.StatusCode
+			}
+
+			It("should draw the line", func() {
+				event, status := post(`{"from": {"x": 1, "y": 2}, "to": {"x": 7, "y": 3}, "color": "#00ff00"}`, nil)
+				Expect(status).Should(Equal(http.StatusOK))
+				Expect(event.X0).Should(BeEquivalentTo(1))
+				Expect(event.Y0).Should(BeEquivalentTo(2))
+				Expect(event.X1).Should(BeEquivalentTo(7))
+				Expect(event.Y1).Should(BeEquivalentTo(3))
+				Expect(*event.Color).Should(BeEquivalentTo(0x00FF00))
+			})
+
+			It("should use the current color", func() {
+				event, status := post(`{"from": {"x": 1, "y": 2}, "to": {"x": 7, "y": 3}}`, nil)
+				Expect(status).Should(Equal(http.StatusOK))
+				Expect(event.Color).Should(BeNil())
+			})
+
+			It("should return the controller error", func() {
+				_, status := post(`{"from": {"x": 1, "y": 2}, "to": {"x": 70, "y": 3}}`, fmt.Errorf("outside the canvas"))
+				Expect(status).Should(Equal(http.StatusBadRequest))
+			})
+
+			It("should reject a line without end point", func() {
+				res, err := server.Client().Post(server.URL+"/api/canvas/line", "application/json", strings.NewReader(`{"from": {"x": 1, "y": 2}}`))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.StatusCode).Should(Equal(http.StatusBadRequest))
+				Consistently(ce).ShouldNot(Receive())
+			})
+		})
+
+		Context("test plot request", func() {
+			// post sends the request, and replies to the plot event with replyErr
+			post := func(reqBody string, replyErr error) (ClientEventPlot, int) {
+				resCh := make(chan *http.Response, 1)
+				go func() {
+					defer GinkgoRecover()
+					res, err := server.Client().Post(server.URL+"/api/canvas/plot", "application/json", strings.NewReader(reqBody))
+					Expect(err).ToNot(HaveOccurred())
+					resCh <- res
+				}()
+
+				var event ClientEventPlot
+				Eventually(ce).Should(Receive(&event))
+				event.Result <- replyErr
+
+				res := <-resCh
+				return event, res.StatusCode
+			}
+
+			It("should start the plot", func() {
+				event, status := post(`{"sensor": "humidity", "x": 1, "y": 2, "width": 8, "height": 4, "style": "line", "min": 20, "max": 80, "colors": ["#0000ff", "#ff0000"], "interval": "2s"}`, nil)
+				Expect(status).Should(Equal(http.StatusOK))
+				Expect(event.Sensor).Should(Equal("humidity"))
+				Expect([]uint8{event.X, event.Y, event.Width, event.Height}).Should(Equal([]uint8{1, 2, 8, 4}))
+				Expect(event.Style).Should(Equal("line"))
+				Expect([]float64{event.Min, event.Max}).Should(Equal([]float64{20, 80}))
+				Expect(event.Colors).Should(Equal([]common.Color{0x0000FF, 0xFF0000}))
+				Expect(event.Interval).Should(Equal(2 * time.Second))
+			})
+
+			It("should stop the plot", func() {
+				event, status := post(`{"stop": true, "sensor": "humidity"}`, nil)
+				Expect(status).Should(Equal(http.StatusOK))
+				Expect(event.Sensor).Should(BeEmpty())
+			})
+
+			It("should return the controller error", func() {
+				_, status := post(`{"sensor": "humidity", "width": 80, "height": 4}`, fmt.Errorf("outside the canvas"))
+				Expect(status).Should(Equal(http.StatusBadRequest))
+			})
+
+			DescribeTable("should reject a wrong request", func(reqBody string) {
+				res, err := server.Client().Post(server.URL+"/api/canvas/plot", "application/json", strings.NewReader(reqBody))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(res.StatusCode).Should(Equal(http.StatusBadRequest))
+				Consistently(ce).ShouldNot(Receive())
+			},
+				Entry("missing sensor", `{"width": 8, "height": 4}`),
+				Entry("wrong interval", `{"sensor": "humidity", "interval": "often"}`),
+			)
+		})
+
+		Context("test display settings request", func() {
+			// post sends the request, and replies with the validation of the new settings, as the controller does
+			post := func(reqBody string) (ClientEventSetDisplaySettings, *http.Response) {
+				resCh := make(chan *http.Response, 1)
+				go func() {
+					defer GinkgoRecover()
+					res, err := server.Client().Post(server.URL+"/api/display/settings", "application/json", strings.NewReader(reqBody))
+					Expect(err).ToNot(HaveOccurred())
+					resCh <- res
+				}()
+
+				var event ClientEventSetDisplaySettings
+				Eventually(ce).Should(Receive(&event))
+				settings := hat.NewDisplaySettings()
+				if event.Gamma != nil {
+					settings.Gamma = *event.Gamma
+				}
+				if event.Brightness != nil {
+					settings.Brightness = *event.Brightness
+				}
+				event.Result <- settings.Validate()
+
+				return event, <-resCh
+			}
+
+			It("should set the display settings", func() {
+				event, res := post(`{"brightness": 50}`)
+				Expect(res.StatusCode).Should(Equal(http.StatusOK))
+				Expect(*event.Brightness).Should(BeEquivalentTo(50))
+				Expect(event.Gamma).Should(BeNil())
+			})
+
+			DescribeTable("should return the validation error", func(reqBody, expected string) {
+				_, res := post(reqBody)
+				defer res.Body.Close()
+				Expect(res.StatusCode).Should(Equal(http.StatusBadRequest))
+
+				errMsg := &errorResponse{}
+				Expect(json.NewDecoder(res.Body).Decode(errMsg)).To(Succeed())
+				Expect(errMsg.Error).Should(ContainSubstring(expected))
+			},
+				Entry("invalid gamma", `{"gamma": [`+strings.Repeat("0, ", 31)+`40]}`, "gamma"),
+				Entry("too bright", `{"brightness": 101}`, "brightness"),
+			)
+		})
+
+		It("should reject wrong gamma table size", func() {
+			url := server.URL + "/api/display/settings"
+
+			res, err := server.Client().Post(url, "application/json", strings.NewReader(`{"gamma": [0, 1, 2]}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.StatusCode).Should(Equal(http.StatusBadRequest))
+			Consistently(ce).ShouldNot(Receive())
+		})
 	})
 
 	Context("test download request", func() {
@@ -327,10 +557,36 @@ var _ = Describe("Test the web application", func() {
 
 			Consistently(ce).ShouldNot(Receive())
 		})
+
+		It("should return the display health", func() {
+			go func() {
+				clientEvent := <-ce
+				if cb, ok := clientEvent.(ClientEventDisplayHealth); ok {
+					cb <- []hat.SinkHealth{{Name: "hat", Healthy: true, Frames: 3}}
+				}
+			}()
+
+			res, err := server.Client().Get(server.URL + "/api/display/health")
+			Expect(err).ToNot(HaveOccurred())
+			defer res.Body.Close()
+			Expect(res.StatusCode).Should(Equal(http.StatusOK))
+			Expect(res.Header.Get("Content-Type")).Should(Equal("application/json"))
+
+			var health []hat.SinkHealth
+			Expect(json.NewDecoder(res.Body).Decode(&health)).To(Succeed())
+			Expect(health).Should(HaveLen(1))
+			Expect(health[0].Name).Should(Equal("hat"))
+			Expect(health[0].Healthy).Should(BeTrue())
+			Expect(health[0].Frames).Should(BeEquivalentTo(3))
+		})
 	})
 
 })
 
+func pointerTo[T any](v T) *T {
+	return &v
+}
+
 type errorResponse struct {
 	Error string `json:"error,omitempty"`
 }